@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"gitlab.com/NebulousLabs/bolt"
+	"go.sia.tech/multisign/foundation"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/types"
+)
+
+// An archive is a durable record of a completed subsidy operation: the
+// transaction file (including its annotations), its confirmation status, and
+// its payment manifest. Archives are stored as gzip-compressed JSON, with a
+// checksum of the contents.
+type archive struct {
+	Contents json.RawMessage `json:"contents"`
+	Checksum crypto.Hash     `json:"checksum"`
+}
+
+type archiveContents struct {
+	Created      time.Time            `json:"created"`
+	Transaction  json.RawMessage      `json:"transaction"`
+	Confirmation *archiveConfirmation `json:"confirmation,omitempty"`
+	Manifest     []manifestEntry      `json:"manifest"`
+}
+
+type archiveConfirmation struct {
+	Height    types.BlockHeight `json:"height"`
+	Confirmed bool              `json:"confirmed"`
+}
+
+// isArchive reports whether data appears to be a (gzip-compressed) archive.
+func isArchive(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+// writeArchive writes an archive of f to filename. If consensusPath is
+// non-empty, the transaction's confirmation status is looked up and recorded.
+func writeArchive(filename string, f txnFile, consensusPath string) {
+	c := archiveContents{
+		Created:     time.Now().UTC().Truncate(time.Second),
+		Transaction: encodeTxnFile(f),
+		Manifest:    paymentManifest(f.txn, addressNames()),
+	}
+	if consensusPath != "" {
+		db := openConsensusDB(consensusPath)
+		c.Confirmation = new(archiveConfirmation)
+		db.View(func(tx *bolt.Tx) error {
+			c.Confirmation.Height = foundation.CurrentHeight(tx)
+			c.Confirmation.Confirmed = txnConfirmed(tx, f.txn.ID(), f.txn)
+			return nil
+		})
+		db.Close()
+	}
+	contents, _ := json.Marshal(c)
+	js, _ := json.Marshal(archive{Contents: contents, Checksum: crypto.HashBytes(contents)})
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(js)
+	check(zw.Close(), "Could not compress archive")
+	check(ioutil.WriteFile(filename, buf.Bytes(), 0666), "Could not write archive")
+}
+
+// parseArchive decompresses an archive and verifies its checksum.
+func parseArchive(data []byte) (archiveContents, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return archiveContents{}, err
+	}
+	js, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return archiveContents{}, err
+	}
+	var a archive
+	if err := json.Unmarshal(js, &a); err != nil {
+		return archiveContents{}, err
+	} else if crypto.HashBytes(a.Contents) != a.Checksum {
+		return archiveContents{}, errors.New("archive checksum mismatch; the archive is corrupt or has been altered")
+	}
+	var c archiveContents
+	err = json.Unmarshal(a.Contents, &c)
+	return c, err
+}
+
+// printArchiveInfo prints the metadata recorded in an archive.
+func printArchiveInfo(c archiveContents) {
+	fmt.Println("Archive created:", c.Created.Format(time.RFC3339))
+	switch {
+	case c.Confirmation == nil:
+		fmt.Println("Confirmation:    not recorded")
+	case c.Confirmation.Confirmed:
+		fmt.Printf("Confirmation:    confirmed as of height %v\n", c.Confirmation.Height)
+	default:
+		fmt.Printf("Confirmation:    NOT confirmed as of height %v\n", c.Confirmation.Height)
+	}
+	fmt.Println("Payments:")
+	for _, e := range c.Manifest {
+		name := ""
+		if e.Name != "" {
+			name = " (" + e.Name + ")"
+		}
+		fmt.Printf("  %v SC to %v%v\n", e.ValueSC, e.Address, name)
+	}
+	if len(c.Manifest) == 0 {
+		fmt.Println("  none")
+	}
+	fmt.Println()
+}
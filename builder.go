@@ -0,0 +1,381 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"go.sia.tech/multisign/foundation"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/types"
+)
+
+// A txnSpec describes a transaction to be built non-interactively. Values are
+// specified in SC, as decimal strings.
+type txnSpec struct {
+	Inputs           []specInput                       `json:"inputs"`
+	Outputs          []specOutput                      `json:"outputs"`
+	MinerFees        []string                          `json:"minerFees,omitempty"`
+	FoundationUpdate *types.FoundationUnlockHashUpdate `json:"foundationUpdate,omitempty"`
+	ArbitraryData    []string                          `json:"arbitraryData,omitempty"`
+}
+
+type specInput struct {
+	ParentID         types.SiacoinOutputID  `json:"parentID"`
+	UnlockConditions types.UnlockConditions `json:"unlockConditions"`
+	Value            string                 `json:"value"`
+}
+
+type specOutput struct {
+	Address types.UnlockHash `json:"address"`
+	Value   string           `json:"value"`
+}
+
+func readTxnSpec(filename string) (txnSpec, error) {
+	js, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return txnSpec{}, err
+	}
+	if err := validateSpec(js); err != nil {
+		return txnSpec{}, err
+	}
+	var spec txnSpec
+	err = json.Unmarshal(js, &spec)
+	return spec, err
+}
+
+// specSchema is a JSON Schema describing the spec format, printed by
+// "multisign txn -schema" for use by other tools. Specs are validated against
+// it before building. The custom formats are checked by specFormats.
+const specSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "multisign transaction spec",
+  "type": "object",
+  "required": ["inputs"],
+  "additionalProperties": false,
+  "properties": {
+    "inputs": {
+      "type": "array",
+      "minItems": 1,
+      "items": {
+        "type": "object",
+        "required": ["parentID", "unlockConditions", "value"],
+        "additionalProperties": false,
+        "properties": {
+          "parentID": {"type": "string", "format": "sia-id"},
+          "unlockConditions": {"type": "object", "format": "sia-unlock-conditions"},
+          "value": {"type": "string", "format": "sia-amount"}
+        }
+      }
+    },
+    "outputs": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["address", "value"],
+        "additionalProperties": false,
+        "properties": {
+          "address": {"type": "string", "format": "sia-address"},
+          "value": {"type": "string", "format": "sia-amount"}
+        }
+      }
+    },
+    "minerFees": {
+      "type": "array",
+      "items": {"type": "string", "format": "sia-amount"}
+    },
+    "foundationUpdate": {
+      "type": "object",
+      "required": ["newPrimary", "newFailsafe"],
+      "additionalProperties": false,
+      "properties": {
+        "newPrimary": {"type": "string", "format": "sia-address"},
+        "newFailsafe": {"type": "string", "format": "sia-address"}
+      }
+    },
+    "arbitraryData": {
+      "type": "array",
+      "items": {"type": "string", "format": "hex-or-file"}
+    }
+  }
+}
+`
+
+// A schemaNode is the subset of JSON Schema used by specSchema.
+type schemaNode struct {
+	Type                 string                 `json:"type"`
+	Format               string                 `json:"format"`
+	Properties           map[string]*schemaNode `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *schemaNode            `json:"items"`
+	MinItems             int                    `json:"minItems"`
+}
+
+// A specValidator checks the JSON value at path, returning a description of
+// each problem found.
+type specValidator func(path string, js json.RawMessage) []string
+
+// specFormats maps each custom format used in specSchema to its validator.
+var specFormats = map[string]specValidator{
+	"sia-id":                specHash,
+	"sia-address":           specAddress,
+	"sia-amount":            specValue,
+	"sia-unlock-conditions": specUnlockConditions,
+	"hex-or-file":           specArbitraryData,
+}
+
+// validateSpec checks js against specSchema, reporting every malformed,
+// missing, or unrecognized field by its path, e.g. "inputs[0].value".
+func validateSpec(js []byte) error {
+	var schema schemaNode
+	if err := json.Unmarshal([]byte(specSchema), &schema); err != nil {
+		panic(err) // should never happen
+	}
+	problems := schema.validate("spec", js)
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid spec:\n  %v", strings.Join(problems, "\n  "))
+}
+
+// validate checks the JSON value at path against n. Formats are only checked
+// if the value has the expected type.
+func (n *schemaNode) validate(path string, js json.RawMessage) []string {
+	var problems []string
+	switch n.Type {
+	case "object":
+		problems = n.validateObject(path, js)
+	case "array":
+		problems = n.validateArray(path, js)
+	case "string":
+		_, problems = specString(path, js)
+	}
+	if len(problems) == 0 && n.Format != "" {
+		problems = specFormats[n.Format](path, js)
+	}
+	return problems
+}
+
+func (n *schemaNode) validateObject(path string, js json.RawMessage) []string {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(js, &obj); err != nil || obj == nil {
+		return []string{path + ": expected an object"}
+	}
+	required := make(map[string]bool)
+	for _, name := range n.Required {
+		required[name] = true
+	}
+	names := make([]string, 0, len(n.Properties))
+	for name := range n.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var problems []string
+	for _, name := range names {
+		if v, ok := obj[name]; ok {
+			problems = append(problems, n.Properties[name].validate(path+"."+name, v)...)
+		} else if required[name] {
+			problems = append(problems, fmt.Sprintf("%v.%v: missing required field", path, name))
+		}
+	}
+	if n.AdditionalProperties != nil && !*n.AdditionalProperties {
+		var unrecognized []string
+		for name := range obj {
+			if _, ok := n.Properties[name]; !ok {
+				unrecognized = append(unrecognized, fmt.Sprintf("%v.%v: unrecognized field", path, name))
+			}
+		}
+		sort.Strings(unrecognized)
+		problems = append(problems, unrecognized...)
+	}
+	return problems
+}
+
+func (n *schemaNode) validateArray(path string, js json.RawMessage) []string {
+	var arr []json.RawMessage
+	if err := json.Unmarshal(js, &arr); err != nil || arr == nil {
+		return []string{path + ": expected an array"}
+	} else if len(arr) < n.MinItems {
+		if n.MinItems == 1 {
+			return []string{path + ": must not be empty"}
+		}
+		return []string{fmt.Sprintf("%v: must have at least %v elements", path, n.MinItems)}
+	}
+	var problems []string
+	for i, v := range arr {
+		problems = append(problems, n.Items.validate(fmt.Sprintf("%v[%v]", path, i), v)...)
+	}
+	return problems
+}
+
+func specString(path string, js json.RawMessage) (string, []string) {
+	var s string
+	if err := json.Unmarshal(js, &s); err != nil {
+		return "", []string{fmt.Sprintf("%v: expected a string, got %v", path, string(js))}
+	}
+	return s, nil
+}
+
+func specValue(path string, js json.RawMessage) []string {
+	s, problems := specString(path, js)
+	if problems != nil {
+		return problems
+	}
+	var c types.Currency
+	if !parseCurrency(s, &c) {
+		return []string{fmt.Sprintf("%v: %q is not a valid amount (e.g. \"1.5\", \"10KS\", \"500H\", or \"1,500\")", path, s)}
+	}
+	return nil
+}
+
+func specHash(path string, js json.RawMessage) []string {
+	s, problems := specString(path, js)
+	if problems != nil {
+		return problems
+	}
+	var h crypto.Hash
+	if err := h.LoadString(s); err != nil {
+		return []string{fmt.Sprintf("%v: %q is not a valid ID (%v)", path, s, err)}
+	}
+	return nil
+}
+
+func specAddress(path string, js json.RawMessage) []string {
+	s, problems := specString(path, js)
+	if problems != nil {
+		return problems
+	}
+	var addr types.UnlockHash
+	if err := addr.LoadString(s); err != nil {
+		return []string{fmt.Sprintf("%v: %q is not a valid address (%v)", path, s, err)}
+	}
+	return nil
+}
+
+func specUnlockConditions(path string, js json.RawMessage) []string {
+	var uc types.UnlockConditions
+	if err := json.Unmarshal(js, &uc); err != nil {
+		return []string{fmt.Sprintf("%v: invalid UnlockConditions (%v)", path, err)}
+	}
+	var problems []string
+	for _, p := range unlockConditionsProblems(uc) {
+		problems = append(problems, path+": "+p)
+	}
+	return problems
+}
+
+func specArbitraryData(path string, js json.RawMessage) []string {
+	s, problems := specString(path, js)
+	if problems != nil {
+		return problems
+	}
+	if !strings.HasPrefix(s, "@") {
+		if _, err := hex.DecodeString(s); err != nil {
+			return []string{fmt.Sprintf("%v: %q is neither hex nor an @file reference", path, s)}
+		}
+	}
+	return nil
+}
+
+// parseArbitraryData parses s as a raw arbitrary data entry: either hex, or
+// "@" followed by the name of a file containing the raw bytes.
+func parseArbitraryData(s string) ([]byte, error) {
+	if strings.HasPrefix(s, "@") {
+		return ioutil.ReadFile(strings.TrimPrefix(s, "@"))
+	}
+	return hex.DecodeString(s)
+}
+
+// specInputValues returns the value of each input in spec, keyed by ParentID.
+func specInputValues(spec txnSpec) map[string]types.Currency {
+	values := make(map[string]types.Currency)
+	for _, in := range spec.Inputs {
+		var v types.Currency
+		if parseCurrency(in.Value, &v) {
+			values[in.ParentID.String()] = v
+		}
+	}
+	return values
+}
+
+// buildTxn constructs a transaction from spec. If the spec does not list any
+// miner fees, then as in the wizard, any input value not assigned to an output
+// is used as the miner fee; if that fee would exceed maxMinerFee, the spec is
+// rejected, since it most likely lacks a change output. Otherwise, the outputs
+// and fees must sum to exactly the input value. Any Foundation update is placed
+// among the arbitrary data as by foundation.PlaceUpdate with updateIndex.
+func buildTxn(spec txnSpec, updateIndex int) (txn types.Transaction, err error) {
+	if len(spec.Inputs) == 0 {
+		return types.Transaction{}, fmt.Errorf("spec has no inputs")
+	}
+	var inputSum types.Currency
+	for i, in := range spec.Inputs {
+		var v types.Currency
+		if !parseCurrency(in.Value, &v) {
+			return types.Transaction{}, fmt.Errorf("input %v: invalid value %q", i, in.Value)
+		}
+		txn.SiacoinInputs = append(txn.SiacoinInputs, types.SiacoinInput{
+			ParentID:         in.ParentID,
+			UnlockConditions: in.UnlockConditions,
+		})
+		inputSum = inputSum.Add(v)
+	}
+	var outputSum types.Currency
+	for i, out := range spec.Outputs {
+		var v types.Currency
+		if !parseCurrency(out.Value, &v) {
+			return types.Transaction{}, fmt.Errorf("output %v: invalid value %q", i, out.Value)
+		} else if out.Address == (types.UnlockHash{}) {
+			return types.Transaction{}, fmt.Errorf("output %v: refusing to send to the zero address", i)
+		}
+		txn.SiacoinOutputs = append(txn.SiacoinOutputs, types.SiacoinOutput{
+			Value:      v,
+			UnlockHash: out.Address,
+		})
+		outputSum = outputSum.Add(v)
+	}
+	if outputSum.Cmp(inputSum) > 0 {
+		return types.Transaction{}, fmt.Errorf("outputs (%v) exceed inputs (%v)", formatSC(outputSum), formatSC(inputSum))
+	}
+	if len(spec.MinerFees) == 0 {
+		fee := inputSum.Sub(outputSum)
+		if fee.Cmp(maxMinerFee) > 0 {
+			return types.Transaction{}, fmt.Errorf("the remaining input value of %v would all be paid as a miner fee, exceeding the maximum of %v; add a change output, or list the fee in minerFees to confirm it", formatSC(fee), formatSC(maxMinerFee))
+		} else if !fee.IsZero() {
+			txn.MinerFees = append(txn.MinerFees, fee)
+		}
+	} else {
+		var feeSum types.Currency
+		for i, s := range spec.MinerFees {
+			var fee types.Currency
+			if !parseCurrency(s, &fee) {
+				return types.Transaction{}, fmt.Errorf("miner fee %v: invalid value %q", i, s)
+			}
+			txn.MinerFees = append(txn.MinerFees, fee)
+			feeSum = feeSum.Add(fee)
+		}
+		if total := outputSum.Add(feeSum); total.Cmp(inputSum) != 0 {
+			return types.Transaction{}, fmt.Errorf("outputs plus miner fees (%v) do not equal inputs (%v)", formatSC(total), formatSC(inputSum))
+		}
+	}
+	for i, s := range spec.ArbitraryData {
+		arb, err := parseArbitraryData(s)
+		if err != nil {
+			return types.Transaction{}, fmt.Errorf("arbitrary data %v: %w", i, err)
+		}
+		txn.ArbitraryData = append(txn.ArbitraryData, arb)
+	}
+	if spec.FoundationUpdate != nil {
+		arb := foundation.UpdateArbitraryData(spec.FoundationUpdate.NewPrimary, spec.FoundationUpdate.NewFailsafe)
+		if err := foundation.PlaceUpdate(&txn, arb, updateIndex); err != nil {
+			return types.Transaction{}, fmt.Errorf("foundation update: %w", err)
+		}
+	} else if updateIndex >= 0 {
+		return types.Transaction{}, fmt.Errorf("an update index was given, but the spec has no foundation update")
+	}
+	return txn, nil
+}
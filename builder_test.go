@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const (
+	testSpecAddress = "0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20441edc56cebc"
+	testSpecID      = "0000000000000000000000000000000000000000000000000000000000000001"
+	testSpecKey1    = "ed25519:1111111111111111111111111111111111111111111111111111111111111111"
+	testSpecKey2    = "ed25519:2222222222222222222222222222222222222222222222222222222222222222"
+)
+
+// testSpec returns a valid spec, decoded as generic JSON so that tests can
+// break it.
+func testSpec() map[string]interface{} {
+	js := `{
+		"inputs": [{
+			"parentID": "` + testSpecID + `",
+			"unlockConditions": {"publicKeys": ["` + testSpecKey1 + `", "` + testSpecKey2 + `"], "signaturesRequired": 2},
+			"value": "100"
+		}],
+		"outputs": [{"address": "` + testSpecAddress + `", "value": "99"}],
+		"minerFees": ["1"],
+		"foundationUpdate": {"newPrimary": "` + testSpecAddress + `", "newFailsafe": "` + testSpecAddress + `"},
+		"arbitraryData": ["deadbeef", "@notes.bin"]
+	}`
+	var spec map[string]interface{}
+	if err := json.Unmarshal([]byte(js), &spec); err != nil {
+		panic(err)
+	}
+	return spec
+}
+
+func testSpecInput(spec map[string]interface{}) map[string]interface{} {
+	return spec["inputs"].([]interface{})[0].(map[string]interface{})
+}
+
+func TestValidateSpec(t *testing.T) {
+	js, _ := json.Marshal(testSpec())
+	if err := validateSpec(js); err != nil {
+		t.Fatal("valid spec was rejected:", err)
+	}
+
+	// amounts are accepted in any form that parseCurrency accepts
+	for _, v := range []string{"10KS", "500H", "1,500", "1,234.5 SC", "0.001"} {
+		spec := testSpec()
+		testSpecInput(spec)["value"] = v
+		js, _ := json.Marshal(spec)
+		if err := validateSpec(js); err != nil {
+			t.Errorf("valid amount %q was rejected: %v", v, err)
+		}
+	}
+
+	tests := []struct {
+		desc   string
+		modify func(spec map[string]interface{})
+		want   string
+	}{
+		{"missing inputs", func(s map[string]interface{}) { delete(s, "inputs") }, "spec.inputs: missing required field"},
+		{"unrecognized field", func(s map[string]interface{}) { s["fee"] = "1" }, "spec.fee: unrecognized field"},
+		{"inputs not an array", func(s map[string]interface{}) { s["inputs"] = "x" }, "spec.inputs: expected an array"},
+		{"empty inputs", func(s map[string]interface{}) { s["inputs"] = []interface{}{} }, "spec.inputs: must not be empty"},
+		{"input not an object", func(s map[string]interface{}) { s["inputs"] = []interface{}{1} }, "spec.inputs[0]: expected an object"},
+		{"input missing value", func(s map[string]interface{}) { delete(testSpecInput(s), "value") }, "spec.inputs[0].value: missing required field"},
+		{"value not a string", func(s map[string]interface{}) { testSpecInput(s)["value"] = 100 }, "spec.inputs[0].value: expected a string"},
+		{"value not a number", func(s map[string]interface{}) { testSpecInput(s)["value"] = "lots" }, "spec.inputs[0].value: \"lots\" is not a valid amount"},
+		{"negative value", func(s map[string]interface{}) { testSpecInput(s)["value"] = "-1" }, "spec.inputs[0].value: \"-1\" is not a valid amount"},
+		{"invalid parent ID", func(s map[string]interface{}) { testSpecInput(s)["parentID"] = "xyz" }, "spec.inputs[0].parentID: \"xyz\" is not a valid ID"},
+		{"unlock conditions not an object", func(s map[string]interface{}) { testSpecInput(s)["unlockConditions"] = "x" }, "spec.inputs[0].unlockConditions: expected an object"},
+		{"invalid unlock conditions", func(s map[string]interface{}) {
+			testSpecInput(s)["unlockConditions"].(map[string]interface{})["publicKeys"] = 5
+		}, "spec.inputs[0].unlockConditions: invalid UnlockConditions"},
+		{"zero threshold", func(s map[string]interface{}) {
+			testSpecInput(s)["unlockConditions"].(map[string]interface{})["signaturesRequired"] = 0
+		}, "spec.inputs[0].unlockConditions: no signatures are required"},
+		{"duplicate keys", func(s map[string]interface{}) {
+			testSpecInput(s)["unlockConditions"].(map[string]interface{})["publicKeys"] = []interface{}{testSpecKey1, testSpecKey1}
+		}, "spec.inputs[0].unlockConditions: key 1 is a duplicate of key 0"},
+		{"invalid output address", func(s map[string]interface{}) {
+			s["outputs"].([]interface{})[0].(map[string]interface{})["address"] = testSpecAddress[:70] + "000000"
+		}, "spec.outputs[0].address: "},
+		{"fractional value", func(s map[string]interface{}) { testSpecInput(s)["value"] = "1/3" }, "spec.inputs[0].value: \"1/3\" is not a valid amount"},
+		{"exponent value", func(s map[string]interface{}) { testSpecInput(s)["value"] = "1e-30" }, "spec.inputs[0].value: \"1e-30\" is not a valid amount"},
+		{"sub-hasting value", func(s map[string]interface{}) { testSpecInput(s)["value"] = "0.5H" }, "spec.inputs[0].value: \"0.5H\" is not a valid amount"},
+		{"malformed separator", func(s map[string]interface{}) { testSpecInput(s)["value"] = "1,5" }, "spec.inputs[0].value: \"1,5\" is not a valid amount"},
+		{"invalid miner fee", func(s map[string]interface{}) { s["minerFees"] = []interface{}{"1SC!"} }, "spec.minerFees[0]: \"1SC!\" is not a valid amount"},
+		{"update missing failsafe", func(s map[string]interface{}) {
+			delete(s["foundationUpdate"].(map[string]interface{}), "newFailsafe")
+		}, "spec.foundationUpdate.newFailsafe: missing required field"},
+		{"invalid arbitrary data", func(s map[string]interface{}) { s["arbitraryData"] = []interface{}{"not hex"} }, "spec.arbitraryData[0]: \"not hex\" is neither hex nor an @file reference"},
+	}
+	for _, test := range tests {
+		spec := testSpec()
+		test.modify(spec)
+		js, _ := json.Marshal(spec)
+		err := validateSpec(js)
+		if err == nil {
+			t.Errorf("%v: spec was not rejected", test.desc)
+		} else if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%v: expected error containing %q, got %q", test.desc, test.want, err)
+		}
+	}
+
+	if err := validateSpec([]byte(`[]`)); err == nil || !strings.Contains(err.Error(), "spec: expected an object") {
+		t.Errorf("expected non-object spec to be rejected, got %v", err)
+	}
+}
+
+func TestSpecSchema(t *testing.T) {
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(specSchema), &schema); err != nil {
+		t.Fatal("schema is not valid JSON:", err)
+	}
+	// every format used by the schema must have a validator
+	var checkFormats func(v interface{})
+	checkFormats = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if f, ok := v["format"].(string); ok && specFormats[f] == nil {
+				t.Errorf("schema uses format %q, which has no validator", f)
+			}
+			for _, e := range v {
+				checkFormats(e)
+			}
+		case []interface{}:
+			for _, e := range v {
+				checkFormats(e)
+			}
+		}
+	}
+	checkFormats(schema)
+}
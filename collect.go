@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"go.sia.tech/siad/crypto"
+)
+
+// expandTxnFilenames expands each argument into transaction filenames: a
+// directory contributes each .json file within it, and a glob pattern each
+// file it matches. Other arguments are used as-is.
+func expandTxnFilenames(args []string) []string {
+	var filenames []string
+	for _, arg := range args {
+		if fi, err := os.Stat(arg); err == nil && fi.IsDir() {
+			matches, _ := filepath.Glob(filepath.Join(arg, "*.json"))
+			sort.Strings(matches)
+			filenames = append(filenames, matches...)
+		} else if matches, _ := filepath.Glob(arg); len(matches) > 0 {
+			sort.Strings(matches)
+			filenames = append(filenames, matches...)
+		} else {
+			filenames = append(filenames, arg)
+		}
+	}
+	return filenames
+}
+
+// A sigSlot identifies the key a signature is from.
+type sigSlot struct {
+	parentID crypto.Hash
+	keyIndex uint64
+}
+
+// collectSignatures combines the valid signatures of each file in files, which
+// must all contain the same transaction, validated at the same height. The
+// signatures contributed by each file are printed; invalid and duplicate
+// signatures are skipped. If any file diverges from the first, it is reported,
+// and ok is false; ok is also false if there are no files.
+func collectSignatures(filenames []string, files []txnFile) (merged txnFile, ok bool) {
+	if len(files) == 0 {
+		fmt.Println("No transaction files to collect.")
+		return txnFile{}, false
+	}
+	ref := files[0]
+	refID := ref.txn.ID()
+	ok = true
+	for i, f := range files[1:] {
+		if id := f.txn.ID(); id != refID {
+			fmt.Printf("DIVERGED  %v: transaction ID is %v, not %v\n", filenames[i+1], id, refID)
+			ok = false
+		} else if f.height != ref.height {
+			fmt.Printf("DIVERGED  %v: validation height is %v, not %v\n", filenames[i+1], f.height, ref.height)
+			ok = false
+		}
+	}
+	if !ok {
+		return txnFile{}, false
+	}
+
+	merged = ref
+	merged.txn.TransactionSignatures = nil
+	merged.ann.Signers = make(map[string]string)
+	ucMap := unlockConditionsByID(ref.txn)
+	have := make(map[sigSlot]bool)
+	fmt.Println("Transaction ID:", refID)
+	for i, f := range files {
+		var added, dup, invalid int
+		for j, sig := range f.txn.TransactionSignatures {
+			slot := sigSlot{sig.ParentID, sig.PublicKeyIndex}
+			switch {
+			case !validSignature(f.txn, j, ucMap, f.height):
+				invalid++
+			case have[slot]:
+				dup++
+			default:
+				have[slot] = true
+				merged.txn.TransactionSignatures = append(merged.txn.TransactionSignatures, sig)
+				added++
+				fmt.Printf("  %v: signature on %v from key %v\n", filenames[i], sig.ParentID, sig.PublicKeyIndex)
+			}
+		}
+		if added == 0 {
+			fmt.Printf("  %v: no new signatures\n", filenames[i])
+		}
+		if dup > 0 || invalid > 0 {
+			fmt.Printf("  %v: skipped %v duplicate and %v invalid signature(s)\n", filenames[i], dup, invalid)
+		}
+		for k, v := range f.ann.Signers {
+			merged.ann.Signers[k] = v
+		}
+	}
+	sortSignatures(&merged.txn)
+	fmt.Println()
+	return merged, true
+}
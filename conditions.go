@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"go.sia.tech/siad/types"
+)
+
+// A conditionSet maps labels to the UnlockConditions of known addresses, so
+// that the same UnlockConditions need not be pasted for every input that spends
+// from an address.
+type conditionSet map[string]types.UnlockConditions
+
+// conditionSetPath returns the location of the condition set, which can be
+// overridden with the MULTISIGN_CONDITIONS environment variable.
+func conditionSetPath() string {
+	if path := os.Getenv("MULTISIGN_CONDITIONS"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	check(err, "Could not locate config directory")
+	return filepath.Join(dir, "multisign", "conditions.json")
+}
+
+// loadConditionSet reads the condition set from disk. A missing condition set
+// is treated as empty.
+func loadConditionSet() conditionSet {
+	s := make(conditionSet)
+	js, err := ioutil.ReadFile(conditionSetPath())
+	if os.IsNotExist(err) {
+		return s
+	}
+	check(err, "Could not read condition set")
+	check(json.Unmarshal(js, &s), "Could not parse condition set")
+	return s
+}
+
+func (s conditionSet) save() {
+	path := conditionSetPath()
+	check(os.MkdirAll(filepath.Dir(path), 0700), "Could not create condition set directory")
+	m := make(map[string]jsonUnlockConditions, len(s))
+	for label, uc := range s {
+		m[label] = jsonUnlockConditions(uc)
+	}
+	js, _ := json.MarshalIndent(m, "", "  ")
+	check(ioutil.WriteFile(path, append(js, '\n'), 0600), "Could not write condition set")
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"go.sia.tech/siad/types"
+)
+
+// An addressBook maps human-readable names to the public keys of known
+// co-signers.
+type addressBook map[string]types.SiaPublicKey
+
+// addressBookPath returns the location of the address book, which can be
+// overridden with the MULTISIGN_CONTACTS environment variable.
+func addressBookPath() string {
+	if path := os.Getenv("MULTISIGN_CONTACTS"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	check(err, "Could not locate config directory")
+	return filepath.Join(dir, "multisign", "contacts.json")
+}
+
+// loadAddressBook reads the address book from disk. A missing address book is
+// treated as empty.
+func loadAddressBook() addressBook {
+	b := make(addressBook)
+	js, err := ioutil.ReadFile(addressBookPath())
+	if os.IsNotExist(err) {
+		return b
+	}
+	check(err, "Could not read address book")
+	check(json.Unmarshal(js, &b), "Could not parse address book")
+	return b
+}
+
+func (b addressBook) save() {
+	path := addressBookPath()
+	check(os.MkdirAll(filepath.Dir(path), 0700), "Could not create address book directory")
+	js, _ := json.MarshalIndent(b, "", "  ")
+	check(ioutil.WriteFile(path, append(js, '\n'), 0600), "Could not write address book")
+}
+
+// names returns the names in the address book, in sorted order.
+func (b addressBook) names() []string {
+	names := make([]string, 0, len(b))
+	for name := range b {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// nameOf returns the name associated with spk, if any.
+func (b addressBook) nameOf(spk types.SiaPublicKey) (string, bool) {
+	for _, name := range b.names() {
+		if b[name].String() == spk.String() {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// describe returns spk as a string, followed by its name if it has one.
+func (b addressBook) describe(spk types.SiaPublicKey) string {
+	if name, ok := b.nameOf(spk); ok {
+		return spk.String() + " (" + name + ")"
+	}
+	return spk.String()
+}
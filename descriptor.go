@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/types"
+)
+
+// An addressDescriptor is a secret-free record of how a multisig address was
+// constructed: its multisig parameters, and, for each key derived from a known
+// seed, the seed's fingerprint, scheme, and key index. Together with the seeds
+// it references, a descriptor suffices to recreate the address.
+type addressDescriptor struct {
+	Version            int               `json:"version"`
+	Address            types.UnlockHash  `json:"address"`
+	Timelock           types.BlockHeight `json:"timelock"`
+	SignaturesRequired uint64            `json:"signaturesRequired"`
+	Keys               []descriptorKey   `json:"keys"`
+}
+
+type descriptorKey struct {
+	PublicKey  string         `json:"publicKey"`
+	Derivation *keyDerivation `json:"derivation,omitempty"`
+}
+
+type keyDerivation struct {
+	Scheme          string `json:"scheme"`
+	Algorithm       string `json:"algorithm"`
+	SeedFingerprint string `json:"seedFingerprint"`
+	Index           uint64 `json:"index"`
+}
+
+// derivationAlgorithms describes how each scheme derives the key at a given
+// index.
+var derivationAlgorithms = map[string]string{
+	"us":   "ed25519; key seed = BLAKE2b-256(BLAKE2b-256(BIP39 entropy) || uint64le(index))",
+	"siad": "ed25519; key seed = BLAKE2b-256(siad seed || uint64le(index))",
+}
+
+// seedFingerprint returns a short identifier for seed. It is derived from the
+// seed's first public key, so it reveals nothing secret.
+func seedFingerprint(seed signingSeed) string {
+	h := crypto.HashAll("multisign seed fingerprint", seed.PublicKey(0).Key)
+	return hex.EncodeToString(h[:8])
+}
+
+// newAddressDescriptor returns a descriptor for uc, recording the derivation of
+// each key that seed can derive.
+func newAddressDescriptor(uc types.UnlockConditions, seed signingSeed, scheme string, scan keyScan) addressDescriptor {
+	d := addressDescriptor{
+		Version:            1,
+		Address:            uc.UnlockHash(),
+		Timelock:           uc.Timelock,
+		SignaturesRequired: uc.SignaturesRequired,
+		Keys:               make([]descriptorKey, len(uc.PublicKeys)),
+	}
+	indices := scan.find(seed, uc.PublicKeys)
+	fingerprint := seedFingerprint(seed)
+	for i, spk := range uc.PublicKeys {
+		d.Keys[i].PublicKey = spk.String()
+		if index, ok := indices[string(spk.Key)]; ok {
+			d.Keys[i].Derivation = &keyDerivation{
+				Scheme:          scheme,
+				Algorithm:       derivationAlgorithms[scheme],
+				SeedFingerprint: fingerprint,
+				Index:           index,
+			}
+		}
+	}
+	return d
+}
+
+// readAddressDescriptor reads a descriptor from filename.
+func readAddressDescriptor(filename string) (addressDescriptor, error) {
+	js, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return addressDescriptor{}, err
+	}
+	var d addressDescriptor
+	if err := json.Unmarshal(js, &d); err != nil {
+		return addressDescriptor{}, err
+	} else if d.Version != 1 {
+		return addressDescriptor{}, fmt.Errorf("unsupported descriptor version %v", d.Version)
+	}
+	return d, nil
+}
+
+// unlockConditions reconstructs the UnlockConditions described by d, checking
+// that they match the recorded address.
+func (d addressDescriptor) unlockConditions() (types.UnlockConditions, error) {
+	uc := types.UnlockConditions{
+		Timelock:           d.Timelock,
+		SignaturesRequired: d.SignaturesRequired,
+		PublicKeys:         make([]types.SiaPublicKey, len(d.Keys)),
+	}
+	for i, k := range d.Keys {
+		if err := uc.PublicKeys[i].LoadString(k.PublicKey); err != nil {
+			return types.UnlockConditions{}, fmt.Errorf("key %v: invalid public key %q", i, k.PublicKey)
+		}
+	}
+	if uc.UnlockHash() != d.Address {
+		return types.UnlockConditions{}, fmt.Errorf("descriptor parameters produce %v, not the recorded address %v", uc.UnlockHash(), d.Address)
+	}
+	return uc, nil
+}
+
+// rederive re-derives each key in d that was derived from seed, returning a
+// description of each mismatch, along with the number of keys checked. Keys
+// derived from other seeds, or with other schemes, are skipped.
+func (d addressDescriptor) rederive(seed signingSeed, scheme string) (problems []string, checked int) {
+	fingerprint := seedFingerprint(seed)
+	for i, k := range d.Keys {
+		if k.Derivation == nil || k.Derivation.Scheme != scheme || k.Derivation.SeedFingerprint != fingerprint {
+			continue
+		}
+		checked++
+		if pk := seed.PublicKey(k.Derivation.Index).String(); pk != k.PublicKey {
+			problems = append(problems, fmt.Sprintf("key %v: index %v derives %v, not %v", i, k.Derivation.Index, pk, k.PublicKey))
+		}
+	}
+	return
+}
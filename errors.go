@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Kinds of errors returned by the functions backing each command. The CLI maps
+// each kind to a distinct exit code, so that scripts can tell failures apart.
+var (
+	errInvalidSeed     = errors.New("invalid seed")
+	errTxnInvalid      = errors.New("invalid transaction")
+	errNoMatchingKeys  = errors.New("no matching keys")
+	errBroadcastFailed = errors.New("broadcast failed")
+	errLintProblems    = errors.New("lint problems found")
+)
+
+// A kindError tags an error with one of the error kinds above, without
+// altering its message.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e kindError) Error() string        { return e.err.Error() }
+func (e kindError) Unwrap() error        { return e.err }
+func (e kindError) Is(target error) bool { return target == e.kind }
+
+func withKind(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return kindError{kind, err}
+}
+
+// exitCode returns the process exit code corresponding to err.
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, errInvalidSeed):
+		return 2
+	case errors.Is(err, errTxnInvalid):
+		return 3
+	case errors.Is(err, errNoMatchingKeys):
+		return 4
+	case errors.Is(err, errBroadcastFailed):
+		return 5
+	case errors.Is(err, errLintProblems):
+		return 6
+	default:
+		return 1
+	}
+}
+
+// fatal prints err and exits with the corresponding exit code.
+func fatal(err error) {
+	log.Print(err)
+	os.Exit(exitCode(err))
+}
+
+// check exits if err is non-nil, prefixing its message with ctx.
+func check(err error, ctx string) {
+	if err != nil {
+		fatal(fmt.Errorf("%v: %w", ctx, err))
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+
+	"gitlab.com/NebulousLabs/encoding"
+	"go.sia.tech/siad/types"
+)
+
+// maxMinerFee is the largest miner fee that the wizard accepts without explicit
+// confirmation, and above which checkTxn warns. Leftover input value becomes the
+// fee, so a forgotten change output would otherwise silently pay miners a
+// substantial portion of a subsidy.
+var maxMinerFee = types.SiacoinPrecision.Mul64(100)
+
+// A currencyFlag is a flag.Value that parses an amount with parseCurrency.
+type currencyFlag struct{ c *types.Currency }
+
+func (f currencyFlag) String() string {
+	if f.c == nil {
+		return ""
+	}
+	return formatSC(*f.c)
+}
+
+func (f currencyFlag) Set(s string) error {
+	if !parseCurrency(s, f.c) {
+		return errors.New("invalid amount")
+	}
+	return nil
+}
+
+// addMaxFeeFlag adds a flag setting maxMinerFee to cmd.
+func addMaxFeeFlag(cmd *flag.FlagSet) {
+	cmd.Var(currencyFlag{&maxMinerFee}, "max-fee", "largest miner fee to accept without confirmation (e.g. 100SC)")
+}
+
+// feeRate returns the fee rate (per byte) of the transaction in f, over its
+// estimated size once fully signed.
+func feeRate(f txnFile) types.Currency {
+	var fee types.Currency
+	for _, mf := range f.txn.MinerFees {
+		fee = fee.Add(mf)
+	}
+	return fee.Div64(uint64(estimatedSize(f)))
+}
+
+// estimatedSize returns the encoded size of the transaction in f once it is
+// fully signed, assuming each missing signature covers the whole transaction.
+func estimatedSize(f txnFile) int {
+	size := len(encoding.Marshal(f.txn))
+	have, required := signingProgress(f.txn, f.height)
+	sigSize := len(encoding.Marshal(types.TransactionSignature{
+		CoveredFields: types.FullCoveredFields,
+		Signature:     make([]byte, 64),
+	}))
+	return size + (required-have)*sigSize
+}
+
+// feeBucket classifies a fee rate relative to the recommended rate, returning
+// the bucket name along with guidance on likely confirmation.
+func feeBucket(rate, recommended types.Currency) (string, string) {
+	if recommended.IsZero() {
+		return "high", "The node recommends no minimum fee; the transaction should confirm promptly."
+	}
+	ratio := new(big.Rat).SetFrac(rate.Big(), recommended.Big())
+	switch {
+	case ratio.Cmp(big.NewRat(1, 2)) < 0:
+		return "low", "The fee is well below the recommended rate. The transaction may take a long time to confirm, or be dropped; consider raising the fee before broadcasting."
+	case ratio.Cmp(big.NewRat(2, 1)) <= 0:
+		return "medium", "The fee is close to the recommended rate. The transaction should confirm within a few blocks."
+	default:
+		return "high", "The fee is well above the recommended rate. The transaction should confirm promptly, though you may be overpaying."
+	}
+}
+
+// printFeeRate prints the fee rate of the transaction in f, classified against
+// the recommended rate (per byte).
+func printFeeRate(f txnFile, recommended types.Currency) {
+	var fee types.Currency
+	for _, mf := range f.txn.MinerFees {
+		fee = fee.Add(mf)
+	}
+	size := estimatedSize(f)
+	rate := feeRate(f)
+	bucket, guidance := feeBucket(rate, recommended)
+	fmt.Printf("Miner Fee:        %v (%v)\n", fee.HumanString(), formatSC(fee))
+	fmt.Printf("Size (signed):    %v bytes\n", size)
+	fmt.Printf("Fee Rate:         %v/KB\n", rate.Mul64(1000).HumanString())
+	fmt.Printf("Recommended Rate: %v/KB\n", recommended.Mul64(1000).HumanString())
+	fmt.Println("Bucket:          ", bucket)
+	fmt.Println()
+	fmt.Println(guidance)
+}
+
+// A sizeSection is the contribution of one transaction field to its encoded
+// size.
+type sizeSection struct {
+	Name     string `json:"name"`
+	Elements int    `json:"elements"`
+	Bytes    int    `json:"bytes"`
+}
+
+// sizeBreakdown returns the encoded size of each field of the transaction in
+// f. If the transaction is not fully signed, a final section estimates the size
+// of the missing signatures, as in estimatedSize.
+func sizeBreakdown(f txnFile) []sizeSection {
+	var sections []sizeSection
+	var total int
+	for _, field := range txnFields(f.txn) {
+		n := len(encoding.Marshal(field.v))
+		sections = append(sections, sizeSection{field.name, field.count, n})
+		total += n
+	}
+	if missing := estimatedSize(f) - total; missing > 0 {
+		have, required := signingProgress(f.txn, f.height)
+		sections = append(sections, sizeSection{"(missing signatures)", required - have, missing})
+	}
+	return sections
+}
+
+// printSizeBreakdown prints sections as a table, or as JSON.
+func printSizeBreakdown(sections []sizeSection, asJSON bool) {
+	if asJSON {
+		js, _ := json.MarshalIndent(sections, "", "  ")
+		os.Stdout.Write(append(js, '\n'))
+		return
+	}
+	var total int
+	for _, s := range sections {
+		total += s.Bytes
+	}
+	fmt.Printf("%-22v %8v %8v %6v\n", "Section", "Elements", "Bytes", "Share")
+	for _, s := range sections {
+		fmt.Printf("%-22v %8v %8v %5.1f%%\n", s.Name, s.Elements, s.Bytes, 100*float64(s.Bytes)/float64(total))
+	}
+	fmt.Printf("%-22v %8v %8v\n", "Total", "", total)
+}
@@ -0,0 +1,183 @@
+package foundation
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gitlab.com/NebulousLabs/bolt"
+	"gitlab.com/NebulousLabs/encoding"
+	"go.sia.tech/siad/persist"
+	"go.sia.tech/siad/types"
+)
+
+var consensusMetadata = persist.Metadata{
+	Header:  "Consensus Set Database",
+	Version: "0.5.0",
+}
+
+var (
+	// ErrBadHeader is returned when a database is not a consensus database.
+	ErrBadHeader = errors.New("not a consensus set database")
+	// ErrBadVersion is returned when a consensus database has an unsupported
+	// version.
+	ErrBadVersion = errors.New("unsupported consensus set database version")
+	// ErrLocked is returned when a consensus database is locked by another
+	// process, such as a running siad.
+	ErrLocked = errors.New("consensus set database is locked by another process")
+)
+
+// A SubsidyOutput is a Foundation subsidy output.
+type SubsidyOutput struct {
+	Height     types.BlockHeight     `json:"height"`
+	ID         types.SiacoinOutputID `json:"id"`
+	UnlockHash types.UnlockHash      `json:"unlockHash"`
+	Value      types.Currency        `json:"value"`
+}
+
+func checkMetadata(tx *bolt.Tx) error {
+	if tx.Bucket([]byte("BlockHeight")) == nil || tx.Bucket([]byte("BlockPath")) == nil || tx.Bucket([]byte("SiacoinOutputs")) == nil {
+		return ErrBadHeader
+	}
+	if meta := tx.Bucket([]byte("Metadata")); meta != nil {
+		if string(meta.Get([]byte("Header"))) != consensusMetadata.Header {
+			return ErrBadHeader
+		} else if v := string(meta.Get([]byte("Version"))); v != consensusMetadata.Version {
+			return fmt.Errorf("%w: %q", ErrBadVersion, v)
+		}
+	}
+	return nil
+}
+
+// OpenConsensusDB opens a siad consensus database in read-only mode. The
+// database is never opened for writing, so it cannot be modified or upgraded.
+// A running siad holds an exclusive lock on the database; if the lock is not
+// released within a few seconds, ErrLocked is returned.
+func OpenConsensusDB(path string) (*bolt.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{
+		ReadOnly: true,
+		Timeout:  3 * time.Second,
+	})
+	if err == bolt.ErrTimeout {
+		return nil, ErrLocked
+	} else if err != nil {
+		return nil, err
+	}
+	if err := db.View(checkMetadata); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// CurrentHeight returns the height of the consensus database's current block.
+func CurrentHeight(tx *bolt.Tx) (height types.BlockHeight) {
+	encoding.Unmarshal(tx.Bucket([]byte("BlockHeight")).Get([]byte("BlockHeight")), &height)
+	return
+}
+
+// UnlockHashes returns the current Foundation primary and failsafe unlock
+// hashes, and whether they are present in the consensus database (they are
+// absent before the Foundation hardfork).
+func UnlockHashes(tx *bolt.Tx) (primary, failsafe types.UnlockHash, ok bool) {
+	b := tx.Bucket([]byte("FoundationUnlockHashes"))
+	if b == nil {
+		return
+	}
+	v := b.Get([]byte("FoundationUnlockHashes"))
+	if len(v) != len(primary)+len(failsafe) {
+		return
+	}
+	copy(primary[:], v[:len(primary)])
+	copy(failsafe[:], v[len(primary):])
+	return primary, failsafe, true
+}
+
+// SiacoinOutput returns the siacoin output with the specified ID, and whether
+// it exists (i.e. is unspent).
+func SiacoinOutput(tx *bolt.Tx, id types.SiacoinOutputID) (sco types.SiacoinOutput, exists bool) {
+	exists = encoding.Unmarshal(tx.Bucket([]byte("SiacoinOutputs")).Get(id[:]), &sco) == nil
+	return
+}
+
+// AddressBalance returns the total value and number of unspent siacoin outputs
+// at the specified address. Since outputs are not indexed by address, this scans
+// every output in the consensus set.
+func AddressBalance(tx *bolt.Tx, addr types.UnlockHash) (total types.Currency, count int) {
+	ForEachSiacoinOutput(tx, func(_ types.SiacoinOutputID, sco types.SiacoinOutput) {
+		if sco.UnlockHash == addr {
+			total = total.Add(sco.Value)
+			count++
+		}
+	})
+	return
+}
+
+// ForEachSiacoinOutput calls fn on each unspent siacoin output in the
+// consensus set.
+func ForEachSiacoinOutput(tx *bolt.Tx, fn func(types.SiacoinOutputID, types.SiacoinOutput)) {
+	tx.Bucket([]byte("SiacoinOutputs")).ForEach(func(k, v []byte) error {
+		var id types.SiacoinOutputID
+		var sco types.SiacoinOutput
+		if len(k) == len(id) && encoding.Unmarshal(v, &sco) == nil {
+			copy(id[:], k)
+			fn(id, sco)
+		}
+		return nil
+	})
+}
+
+// SubsidyOutputAt returns the subsidy output created at the specified height,
+// and whether it is unspent. If the output has been spent, only its Height and
+// ID are set.
+func SubsidyOutputAt(tx *bolt.Tx, height types.BlockHeight) (out SubsidyOutput, unspent bool) {
+	var bid types.BlockID
+	encoding.Unmarshal(tx.Bucket([]byte("BlockPath")).Get(encoding.Marshal(height)), &bid)
+	out.Height = height
+	out.ID = bid.FoundationSubsidyID()
+	sco, unspent := SiacoinOutput(tx, out.ID)
+	if !unspent {
+		return out, false
+	}
+	out.UnlockHash = sco.UnlockHash
+	out.Value = sco.Value
+	return out, true
+}
+
+// ForEachUnspentSubsidy calls fn on each unspent subsidy output in the
+// consensus database at dbPath, in order of height. Outputs are read one at a
+// time, so memory usage does not grow with the length of the chain. If fn
+// returns an error, iteration stops and the error is returned.
+func ForEachUnspentSubsidy(dbPath string, fn func(SubsidyOutput) error) error {
+	db, err := OpenConsensusDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error {
+		currentHeight := CurrentHeight(tx)
+		for height := types.FoundationHardforkHeight; height < currentHeight; height += types.FoundationSubsidyFrequency {
+			if out, unspent := SubsidyOutputAt(tx, height); unspent {
+				if err := fn(out); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// UnspentSubsidyOutputs returns all of the unspent subsidy outputs in the
+// consensus database at dbPath.
+func UnspentSubsidyOutputs(dbPath string) ([]SubsidyOutput, error) {
+	var outputs []SubsidyOutput
+	err := ForEachUnspentSubsidy(dbPath, func(out SubsidyOutput) error {
+		outputs = append(outputs, out)
+		return nil
+	})
+	return outputs, err
+}
@@ -0,0 +1,100 @@
+// Package foundation provides helpers for working with Foundation subsidy
+// outputs and Foundation unlock hash updates.
+package foundation
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"gitlab.com/NebulousLabs/encoding"
+	"go.sia.tech/siad/types"
+)
+
+// ErrNotUpdate is returned by DecodeUpdate when the arbitrary data does not
+// begin with the Foundation specifier.
+var ErrNotUpdate = errors.New("arbitrary data is not a Foundation unlock hash update")
+
+// UpdateArbitraryData returns the arbitrary data encoding of a Foundation
+// unlock hash update: the Foundation specifier, followed by the encoded update.
+func UpdateArbitraryData(primary, failsafe types.UnlockHash) []byte {
+	return encoding.MarshalAll(types.SpecifierFoundation, types.FoundationUnlockHashUpdate{
+		NewPrimary:  primary,
+		NewFailsafe: failsafe,
+	})
+}
+
+// AddUpdate appends a Foundation unlock hash update to txn.
+func AddUpdate(txn *types.Transaction, primary, failsafe types.UnlockHash) {
+	txn.ArbitraryData = append(txn.ArbitraryData, UpdateArbitraryData(primary, failsafe))
+}
+
+// PlaceUpdate adds arb, the arbitrary data encoding of a Foundation unlock hash
+// update, to txn at position index, first removing any existing updates. If
+// index is negative, arb replaces the first existing update in place, or is
+// appended if there is none.
+func PlaceUpdate(txn *types.Transaction, arb []byte, index int) error {
+	var rest [][]byte
+	existing := -1
+	for i, a := range txn.ArbitraryData {
+		if IsUpdate(a) {
+			if existing < 0 {
+				existing = i
+			}
+			continue
+		}
+		rest = append(rest, a)
+	}
+	if index < 0 {
+		index = len(rest)
+		if existing >= 0 {
+			index = existing
+		}
+	} else if index > len(rest) {
+		return fmt.Errorf("index %v is out of range; transaction has %v other arbitrary data entries", index, len(rest))
+	}
+	txn.ArbitraryData = append(rest[:index:index], append([][]byte{arb}, rest[index:]...)...)
+	return nil
+}
+
+// IsUpdate reports whether arb begins with the Foundation specifier.
+func IsUpdate(arb []byte) bool {
+	return bytes.HasPrefix(arb, types.SpecifierFoundation[:])
+}
+
+// DecodeUpdate decodes a Foundation unlock hash update from arbitrary data.
+func DecodeUpdate(arb []byte) (update types.FoundationUnlockHashUpdate, err error) {
+	if !IsUpdate(arb) {
+		return update, ErrNotUpdate
+	}
+	err = encoding.Unmarshal(arb[types.SpecifierLen:], &update)
+	return
+}
+
+// NextSubsidyHeight returns the height of the first subsidy created after the
+// specified height.
+func NextSubsidyHeight(height types.BlockHeight) types.BlockHeight {
+	if height < types.FoundationHardforkHeight {
+		return types.FoundationHardforkHeight
+	}
+	elapsed := (height - types.FoundationHardforkHeight) / types.FoundationSubsidyFrequency
+	return types.FoundationHardforkHeight + (elapsed+1)*types.FoundationSubsidyFrequency
+}
+
+// IsSubsidyHeight reports whether a subsidy is created at the specified height.
+func IsSubsidyHeight(height types.BlockHeight) bool {
+	return height >= types.FoundationHardforkHeight && (height-types.FoundationHardforkHeight)%types.FoundationSubsidyFrequency == 0
+}
+
+// SubsidyValue returns the value of the subsidy created at the specified
+// height, per the consensus rules. The first subsidy, at the hardfork height,
+// includes an initial lump sum; each subsequent subsidy covers the blocks since
+// the previous one. If no subsidy is created at height, ok is false.
+func SubsidyValue(height types.BlockHeight) (value types.Currency, ok bool) {
+	if !IsSubsidyHeight(height) {
+		return types.ZeroCurrency, false
+	} else if height == types.FoundationHardforkHeight {
+		return types.InitialFoundationSubsidy, true
+	}
+	return types.FoundationSubsidyPerBlock.Mul64(uint64(types.FoundationSubsidyFrequency)), true
+}
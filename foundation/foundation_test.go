@@ -0,0 +1,30 @@
+package foundation
+
+import (
+	"bytes"
+	"testing"
+
+	"go.sia.tech/siad/types"
+)
+
+func TestUpdateRoundTrip(t *testing.T) {
+	primary := types.UnlockHash{1, 2, 3}
+	failsafe := types.UnlockHash{4, 5, 6}
+	arb := UpdateArbitraryData(primary, failsafe)
+	if !bytes.HasPrefix(arb, types.SpecifierFoundation[:]) {
+		t.Fatalf("encoded update does not begin with the Foundation specifier: %x", arb)
+	} else if !IsUpdate(arb) {
+		t.Fatal("IsUpdate does not recognize encoded update")
+	}
+	update, err := DecodeUpdate(arb)
+	if err != nil {
+		t.Fatal(err)
+	} else if update.NewPrimary != primary || update.NewFailsafe != failsafe {
+		t.Fatalf("decoded update (%v, %v) does not match encoded update (%v, %v)", update.NewPrimary, update.NewFailsafe, primary, failsafe)
+	}
+
+	// data without the specifier must be rejected
+	if _, err := DecodeUpdate(arb[types.SpecifierLen:]); err != ErrNotUpdate {
+		t.Fatalf("expected ErrNotUpdate, got %v", err)
+	}
+}
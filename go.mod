@@ -5,6 +5,7 @@ go 1.15
 require (
 	gitlab.com/NebulousLabs/bolt v1.4.4
 	gitlab.com/NebulousLabs/encoding v0.0.0-20200604091946-456c3dc907fe
+	gitlab.com/NebulousLabs/entropy-mnemonics v0.0.0-20181018051301-7532f67e3500
 	go.sia.tech/siad v1.5.7
 	golang.org/x/term v0.0.0-20210421210424-b80969c67360
 	lukechampine.com/flagg v1.1.1
@@ -3,22 +3,29 @@ package main
 import (
 	"bytes"
 	"crypto/ed25519"
+	"encoding/csv"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"math/big"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"gitlab.com/NebulousLabs/bolt"
 	"gitlab.com/NebulousLabs/encoding"
+	"go.sia.tech/multisign/foundation"
 	"go.sia.tech/siad/crypto"
-	"go.sia.tech/siad/persist"
 	"go.sia.tech/siad/types"
-	"golang.org/x/term"
 	"lukechampine.com/flagg"
 	"lukechampine.com/us/ed25519hash"
 	"lukechampine.com/us/wallet"
@@ -32,12 +39,86 @@ var (
 Actions:
     seed            generate a seed
     pubkey          derive a pubkey
+    keys            export a range of pubkeys and addresses as CSV
+    keypubkey       derive a pubkey from a raw ed25519 private key
     addr            derive a multisig address
+    owns            check which keys of a multisig address a seed controls
+    verify-seed     check that a backed-up seed reconstructs a recorded address
+    descriptor      record how a multisig address was derived, without secrets
+    recover-conditions  rebuild lost UnlockConditions from an address and keys
+    prove           prove control of a multisig address by signing a challenge
+    verify-proof    check a proof of control
+    contacts        manage names for co-signer public keys
+    validate-address  check that a multisig address's keys are well-formed
+    verify-address  check that UnlockConditions match an expected address
     outputs         list unspent subsidy outputs
+    nextsubsidy     estimate when the next subsidy will be created
+    subsidyvalue    compute the value of the subsidy created at a height
+    balance         print the spendable balance of an address
+    keyusage        report which of a seed's addresses hold outputs
+    totals          sum unspent subsidy outputs across several addresses
+    change          compute the change left over from a planned spend
     txn             create a transaction
+    rotate          propose a Foundation address update for review, then attach it
+    canisign        preview which inputs of a transaction a seed can sign
     sign            add a signature to a subsidy transaction
     check           print transaction details
+    export          package a transaction into a signing bundle
+    encode          export a fully-signed transaction in siad's binary encoding
+    manifest        list the payments a transaction makes, for accounting
+    archive         package a completed transaction for long-term storage
+    status          print a transaction's signing progress
+    compare         check that several files sign the same transaction
+    collect         merge the signatures of several signed copies
+    revalidate      check whether existing signatures hold at a new height
+    lint            report signatures that refer to nonexistent keys
+    sanity          warn about economically suspicious transaction contents
+    sighash         print the hash each signature must cover
+    import          attach an externally-produced signature
+    arbdata         decode a transaction's arbitrary data
+    annotate        add a comment to a transaction file
+    fmt             rewrite a transaction file in canonical form
+    paste           save a transaction pasted from chat or email
+    feerate         check whether a transaction's fee is likely to confirm
+    size            show how much each part of a transaction adds to its size
+    probe           check that a walrus server is reachable and usable
     broadcast       broadcast a subsidy transaction
+    confirmed       check whether a transaction has been confirmed
+    serve           collect signatures from co-signers over HTTP
+    submit          sign a transaction and submit it to a coordinator
+    selftest        check that building and signing work end to end
+    verify-derivation  check key derivation against known test vectors
+`
+	changeUsage = `Usage:
+    multisign change [input total] [output total] [fee]
+
+Prints the change left over after spending the output total and fee from the
+input total, or an error if the outputs and fee exceed the inputs. Amounts are
+in SC, or may carry a unit suffix, e.g. 1.5MS, 300KS, or 1000H.
+`
+	verifyDerivationUsage = `Usage:
+    multisign verify-derivation
+
+Derives public keys from fixed, publicly known seeds and checks them against
+hardcoded expected values, for both key derivation schemes. A mismatch means
+that key derivation has changed (e.g. due to a dependency update), so that
+keys derived earlier may no longer be reproducible; if that happens, do not use
+this build to sign.
+`
+	fixtureUsage = `Usage:
+    multisign fixture [flags] [file]
+
+FOR TESTING ONLY. Writes a byte-for-byte reproducible m-of-n transaction file,
+spending a fabricated input and signed by the first -sigs of n throwaway seeds,
+for use as a golden fixture in documentation and CI. The seeds are derived from
+public constants and printed, so they must never hold real funds.
+`
+	selftestUsage = `Usage:
+    multisign selftest
+
+Builds, signs, and verifies a 2-of-3 multisig transaction using throwaway seeds
+and a fabricated input, printing PASS or FAIL for each stage. No real funds or
+network access are involved.
 `
 	versionUsage = rootUsage
 	seedUsage    = `Usage:
@@ -46,42 +127,536 @@ Actions:
 Generates a random seed.
 `
 	pubkeyUsage = `Usage:
-    multisign pubkey [key index]
+    multisign pubkey [flags] [key index]
 
-Derives a pubkey from a seed and a key index.
+Derives a pubkey from a seed and a key index. By default, keys are derived from
+a 12-word seed as in us/wallet; pass -scheme siad to derive keys from a siad
+wallet seed instead.
+`
+	keypubkeyUsage = `Usage:
+    multisign keypubkey [private key]
+
+Derives the pubkey corresponding to a hex-encoded ed25519 private key (either
+the 64-byte private key or its 32-byte seed), e.g. one exported from an HSM.
+
+WARNING: private keys passed on the command line may be recorded in your shell
+history and are visible to other processes. Omit the argument to be prompted
+for the key instead, or to pipe it in from another tool. A 64-byte key whose
+public half does not match its seed half is rejected as corrupt.
+`
+	keysUsage = `Usage:
+    multisign keys [flags] [n]
+
+Derives n pubkeys from a seed and prints them as CSV, along with the index and
+standard (single-sig) address of each key. No secret key material is printed.
 `
 	addrUsage = `Usage:
-    multisign addr [timelock] [m] [pubkey1, pubkey2, ...]
+    multisign addr [flags] [timelock] [m] [pubkey1, pubkey2, ...]
+
+Generates a multisig address for receiving subsidies. Pubkeys may also be given
+by their name in the address book (see the contacts command).
+
+If -siad is provided, a standard address from the node's wallet may also be
+given in place of a pubkey; its pubkey is fetched from the wallet API. The API
+password is read from SIA_API_PASSWORD, or from ~/.sia/apipassword.
+
+If -save is provided, the UnlockConditions are also stored under that label in
+the condition set, so that the txn wizard accepts the label in place of JSON.
+The condition set is stored in the user's config directory; set
+MULTISIGN_CONDITIONS to use a different file.
+
+By default, the UnlockConditions are printed in a human-readable JSON form. Use
+-format siad to print them in the JSON form used by siad's API (as returned by
+/wallet/unlockconditions), or -format siad-hex for siad's binary encoding, as
+hex; either is useful for registering the address with siad-based tooling.
+
+If -qr is set, the address is also printed as a QR code, and if -qr-png is
+given, the QR code is written to that PNG file. With -qr-only, the textual
+output is omitted.
+`
+	contactsUsage = `Usage:
+    multisign contacts
+    multisign contacts add [name] [pubkey]
+    multisign contacts remove [name]
+
+Manages the address book of known co-signer public keys. With no arguments, the
+address book is listed. Named keys are displayed by the check and owns
+commands, and names can be used in place of pubkeys in the addr command.
+
+The address book is stored in the user's config directory; set
+MULTISIGN_CONTACTS to use a different file.
+`
+	ownsUsage = `Usage:
+    multisign owns [flags] [unlock conditions]
+    multisign owns [flags] [address] [timelock] [m] [pubkey1, pubkey2, ...]
+
+Reports which public keys of a multisig address can be derived from a seed. The
+address may be specified either as a JSON UnlockConditions object (as printed by
+the addr command), or as an address followed by the same arguments that were
+passed to the addr command.
+`
+	verifySeedUsage = `Usage:
+    multisign verify-seed [flags] [unlock conditions] [address]
+
+Checks that a seed, together with the recorded JSON UnlockConditions, still
+reconstructs the expected address, e.g. as part of a disaster-recovery drill.
+If -index is given, only that seed index is checked; otherwise, the seed is
+scanned. If -position is given, the seed's key must be at that position among
+the address's keys. On mismatch, the differing component (the UnlockConditions
+or the seed) is reported, and the command exits with a non-zero status.
+`
+	descriptorUsage = `Usage:
+    multisign descriptor [flags] [unlock conditions]
+    multisign descriptor load [flags] [file]
+
+Writes a descriptor of a multisig address: a JSON record of its timelock,
+required signatures, and public keys, along with the derivation of each key the
+seed controls (the key index, scheme, and algorithm, and a fingerprint of the
+seed). The descriptor contains no secrets, and serves as a recipe for recreating
+the address. The UnlockConditions may be given as JSON, or as a label in the
+condition set.
+
+With load, the UnlockConditions are reconstructed from a descriptor, checked
+against the recorded address, and printed. If -seed is given, each key the
+descriptor records as derived from that seed is re-derived and checked as well.
+`
+	recoverConditionsUsage = `Usage:
+    multisign recover-conditions [flags] [address] [pubkey1, pubkey2, ...]
+    multisign recover-conditions -seed n [flags] [address]
+
+Reconstructs lost UnlockConditions for an address, given candidate public keys
+(or names in the address book) that include all of its keys. Every ordering of
+every subset of the candidates is tried with every threshold until one hashes
+to the address; smaller subsets are tried first. If -seed is given, the first n
+keys of a seed are added to the candidates. The timelock is not searched, and
+must be given with -timelock if it is non-zero.
+
+The search grows factorially with the number of candidates, so it stops after
+-max-attempts combinations. If no combination matches, the command exits with a
+non-zero status, reporting whether the search was exhaustive.
+`
+	proveUsage = `Usage:
+    multisign prove [flags] -o [proof file] [unlock conditions] [challenge]
+    multisign prove [flags] [proof file]
 
-Generates a multisig address for receiving subsidies.
+Produces a proof that the holders of a multisig address's keys control it, e.g.
+for an exchange or auditor. The verifier supplies a challenge, which each
+co-signer signs with their seed; once enough have signed to meet the address's
+threshold, the proof is complete. The UnlockConditions may be given as JSON, or
+as a label in the condition set.
+
+In the first form, a new proof is created and written to the -output file. In
+the second, signatures are added to an existing proof, e.g. one passed along by
+another co-signer. Seeds are requested one after another until the threshold is
+met; enter an empty seed to stop early. The challenge is signed together with
+the address, under a prefix that prevents the signature from being valid for
+any transaction.
+`
+	verifyProofUsage = `Usage:
+    multisign verify-proof [proof file] [challenge]
+
+Checks a proof of control produced by the prove command: the UnlockConditions
+must match the claimed address, the proof must be for the expected challenge,
+and the number of distinct keys with valid signatures must meet the address's
+threshold. Exits with a non-zero status otherwise.
+`
+	validateAddressUsage = `Usage:
+    multisign validate-address [unlock conditions]
+
+Checks that a JSON UnlockConditions object (as printed by the addr command) is
+well-formed: every public key must be a 32-byte ed25519 key, no key may appear
+twice, and the number of required signatures must be between 1 and the number
+of keys. If all checks pass, the address is printed.
+`
+	verifyAddressUsage = `Usage:
+    multisign verify-address [unlock conditions] [address]
+
+Recomputes the address of a JSON UnlockConditions object and reports whether it
+matches the expected address, e.g. when a co-signer sends both out-of-band. The
+conditions are printed with known keys labelled by their address book names. A
+mismatch exits with a non-zero status.
 `
 	outputsUsage = `Usage:
-    multisign outputs [consensus.db]
+    multisign outputs [flags] [consensus.db]
+
+Lists unspent subsidy outputs in the specified consensus set. Scan progress is
+periodically printed to stderr. With -refs, output IDs are printed as
+checksummed output references, which the txn wizard accepts in place of hex.
+
+A consensus set that is not fully synced will under-report the available
+subsidies. To guard against this, pass -node to compare its height against the
+chain tip reported by a walrus server, or -expected-height to compare against a
+known height; a warning is printed if the consensus set is behind.
+`
+	nextsubsidyUsage = `Usage:
+    multisign nextsubsidy [flags] [height|consensus.db]
+
+Reports how many blocks remain until the next subsidy is created, along with an
+estimate of the wall-clock time. The current height can be supplied directly, or
+read from a consensus set.
+`
+	subsidyvalueUsage = `Usage:
+    multisign subsidyvalue [height]
+
+Prints the value of the subsidy created at the specified height, as computed
+from the consensus rules; no consensus set is needed, so future subsidies can be
+planned for. The height must be one at which a subsidy is created: the hardfork
+height, or a multiple of the subsidy frequency after it.
+`
+	balanceUsage = `Usage:
+    multisign balance [address] [consensus.db]
+
+Prints the total value of all unspent outputs at the specified address in the
+specified consensus set, including (but not limited to) subsidy outputs.
+`
+	keyusageUsage = `Usage:
+    multisign keyusage [flags] [consensus.db] [unlock conditions...]
 
-Lists unspent subsidy outputs in the specified consensus set.
+Derives the standard (single-sig) addresses of a seed, up to the specified
+depth, and reports which of them hold unspent outputs in the consensus set. Any
+multisig addresses given as JSON UnlockConditions are reported as well, along
+with which of their keys the seed controls. This helps choose a safe -depth for
+the sign command's key scan.
+
+Since the consensus set only contains unspent outputs, addresses whose outputs
+have all been spent are reported as unused.
+`
+	totalsUsage = `Usage:
+    multisign totals [flags] [consensus.db] [address1 address2 ...]
+
+Prints the total value of the unspent subsidy outputs at each of the specified
+addresses, along with a grand total. Addresses may also be read from a file,
+one per line.
 `
 	txnUsage = `Usage:
-    multisign txn [file]
+    multisign txn [flags] [file]
 
 Launches the transaction construction wizard. Upon answering all prompts, the
 resulting transaction is written to the specified file. The transaction may
 optionally include a subsidy address update.
+
+Alternatively, the transaction can be built non-interactively from a JSON spec
+file. With -preview, the resulting transaction is printed instead of written.
+The output file may also be given with -output, e.g.
+"multisign txn -spec spec.json -o txn.json". The spec format is described by a
+JSON Schema, printed by -schema, against which specs are validated. As in the wizard, leftover input
+value becomes the miner fee; a spec whose leftover exceeds -max-fee is rejected
+unless the fee is listed explicitly in "minerFees".
+
+Raw arbitrary data entries, given as hex or as @file, can be included with
+-allow-arbitrary-data, either in the spec's "arbitraryData" array or via an
+extra wizard prompt. The check command warns about any entries it does not
+recognize.
+
+Any input value not claimed by an output becomes the miner fee. If the fee would
+exceed -max-fee (100 SC by default), the wizard prints it and asks for more
+outputs, such as a change output, unless the fee is explicitly confirmed.
+
+A subsidy address update is placed after any raw arbitrary data, as with the
+rotate command; use -update-index to place it at a specific position instead.
+`
+	rotateUsage = `Usage:
+    multisign rotate propose [primary] [failsafe] [proposal file]
+    multisign rotate [flags] attach [proposal file] [txn file]
+    multisign rotate preview [txn file] [consensus.db]
+
+Updates the Foundation subsidy addresses in two reviewable steps. First,
+propose writes a proposal file containing only the update and a summary, which
+can be reviewed and approved independently of any spending. Then, attach adds
+the approved update to an unsigned funding transaction (e.g. one created by the
+txn command). Both steps decode the update exactly as the check command would,
+and ask for confirmation.
+
+By default, the update is appended after any existing arbitrary data, or,
+with -replace, placed where the existing update was. Use -index to place it at a
+specific position instead, and -replace to replace an existing update (which
+otherwise prevents attaching a new one).
+
+Before broadcasting, preview reads the current Foundation addresses from the
+consensus set and shows them alongside the addresses that will be in effect
+once the transaction is confirmed.
+`
+	canisignUsage = `Usage:
+    multisign canisign [flags] [file]
+
+Reports, for each input of the transaction, whether the provided seed controls
+any of its public keys, and at which seed index, along with whether those keys
+have already signed. Nothing is signed or written; this is a preview of what
+the sign command would do. The seed is scanned as in the sign command.
 `
 	signUsage = `Usage:
-    multisign sign [file]
+    multisign sign [flags] [file]
 
 Adds a signature to a subsidy transaction. The appropriate key is selected
-automatically from the provided seed.
+automatically from the provided seed. If the file is a URL, the signed
+transaction is written to stdout. If a label is provided, it is recorded in
+the transaction file alongside the signing key(s).
+
+If -strict is set, the transaction is not signed if it contains file contracts,
+storage proofs, siafunds, or unrecognized arbitrary data. The checks of the
+sanity command are also run, and any warnings must be confirmed by typing "yes"
+before signing.
+
+If -keep is set, the signed transaction is written to a new file named after
+the input, the public key index(es) of the new signature(s), and the current
+time, e.g. txn.key1.20240601T150405Z.json.
+
+If -until-complete is set, seeds are requested one after another until the
+transaction is fully signed, or until an empty seed is entered.
+
+When signing in place, the file is not overwritten if it changed after it was
+read (e.g. because someone else added a signature meanwhile). Instead, the
+signed copy is written to a new file, as with -keep, so that the signatures can
+be merged. Pass -force to overwrite the file regardless.
 `
 	checkUsage = `Usage:
-    multisign check [file]
+    multisign check [flags] [file]
 
 Prints transaction details, including whether any attached signatures are valid.
+If a walrus server is provided, the transaction is validated at the server's
+current height; otherwise, a fixed height is used. A warning is printed if the
+miner fee exceeds -max-fee (100 SC by default). With -grouped, signatures are
+listed under the input they sign, along with its signature threshold.
+
+For each invalid signature, check tries to explain why: if the signature would
+be valid at a height in a different hardfork era, the validation height is most
+likely misconfigured. Pass -diagnose=false to disable these hints.
+
+If -heights is given (e.g. -heights 300000,310000), the transaction is also
+validated at each of the listed heights, and the results are printed as a table:
+whether the transaction is valid, how many of its required signatures are
+valid, and whether the timelocks of its inputs have expired at that height.
+`
+	exportUsage = `Usage:
+    multisign export [flags] [file] [bundle file]
+    multisign export [flags] -o [bundle file] [file]
+
+Packages a transaction into a self-contained signing bundle, containing the
+transaction, the UnlockConditions and signature threshold of each input, the
+intended validation height, and any annotations. The sign, check, status, and
+broadcast commands all accept bundles in place of a transaction file, and sign
+and check use the height pinned in the bundle.
+`
+	encodeUsage = `Usage:
+    multisign encode [flags] [file]
+
+Writes a fully-signed transaction in siad's binary encoding, as hex, for use
+with other Sia tools. The transaction must be fully signed and valid; otherwise,
+nothing is written.
+`
+	manifestUsage = `Usage:
+    multisign manifest [flags] [file]
+
+Writes a payment manifest of the transaction: the address and value of each
+output, excluding change (outputs returning to an input's address) and the miner
+fee. Addresses are labelled with their address book or condition set name, where
+known. The manifest is written to stdout as JSON, or as CSV with -format csv.
+`
+	archiveUsage = `Usage:
+    multisign archive [flags] [file] [archive file]
+
+Packages a completed transaction into a single compressed archive for
+long-term storage and audit. The archive contains the transaction file
+(including its annotations), its payment manifest, and, if -consensus is given,
+its confirmation status, along with a checksum of the contents. Archives can be
+read by check (and other commands) in place of a transaction file; check also
+prints the recorded metadata.
+`
+	statusUsage = `Usage:
+    multisign status [file]
+
+Prints the number of valid signatures present and required for each input of
+the transaction. Exits with a non-zero status if more signatures are needed.
+`
+	compareUsage = `Usage:
+    multisign compare [file1] [file2] ...
+
+Checks that several transaction files (e.g. copies signed by different
+co-signers) all contain the same transaction, by comparing their transaction
+IDs, which do not depend on signatures. Files containing detached signatures (a
+JSON array of signatures) are checked by verifying each signature against the
+transaction in the first transaction file. Any divergence is reported, and the
+command exits with a non-zero status; signatures should only be merged if all
+files match.
+`
+	collectUsage = `Usage:
+    multisign collect [flags] [dir|glob|file...]
+
+Merges the signatures from copies of a transaction signed by different
+co-signers. Each argument may be a directory (all .json files within it), a
+glob pattern, or a file. All files must contain the same transaction, validated
+at the same height; if any diverges, it is reported and nothing is written.
+Otherwise, the valid signatures of every file are combined, with duplicates and
+invalid signatures skipped, and written to the -output file. The signatures each
+file contributed are listed, followed by the signing status of each input.
+Exits with a non-zero status if the transaction is still not fully signed.
+`
+	revalidateUsage = `Usage:
+    multisign revalidate [flags] [file] [height]
+
+Checks whether the signatures attached to a transaction are still valid at the
+specified height, e.g. once the timelock of an input has expired, and whether
+they are now sufficient to make the transaction valid.
+
+A signature covers a hash that depends on the height only through replay
+protection, which changes at hardforks; a timelock affects whether the
+transaction is valid, but not what its signatures cover. So signatures made
+before a timelock expires can be reused afterwards, and only need to be
+regenerated if a hardfork occurred in between. If -resign is set, any
+signatures that are no longer valid are removed, and replaced with new
+signatures from the provided seed.
+`
+	sanityUsage = `Usage:
+    multisign sanity [flags] [file]
+
+Warns about properties of a transaction that are valid, but economically
+suspicious: a miner fee above -max-fee, or above -max-fee-percent of the value
+spent; outputs to addresses that are not recognized; and Foundation updates to
+addresses that are not recognized. Recognized addresses are the standard
+addresses of keys in the address book, the addresses in the condition set, and
+(for outputs) the addresses of the transaction's inputs. Exits with a non-zero
+status if there are any warnings.
+`
+	lintUsage = `Usage:
+    multisign lint [file]
+
+Reports orphan signatures, whose ParentID matches no input of the transaction,
+and signatures whose public key index is out-of-bounds for their input. Such
+signatures can accumulate after a transaction is edited. If any problems are
+found, the command prints their count and exits with status 6.
+`
+	sighashUsage = `Usage:
+    multisign sighash [file]
+
+Prints, for each public key of each input, the hash that a signature by that
+key must cover, in hex. The hashes are computed for signatures covering the
+whole transaction, at the height pinned in the transaction file. Signatures of
+these hashes produced by an external signer can be attached with the import
+command.
+`
+	importUsage = `Usage:
+    multisign import [file] [input] [key index] [signature]
+
+Attaches a signature produced by an external signer (see the sighash command)
+to a transaction. The input may be given by its index, by its ParentID, or by
+its checksummed output reference (see outputs -refs), and the signature must be
+hex-encoded. The signature is verified against the referenced public key before
+it is added.
+`
+	arbdataUsage = `Usage:
+    multisign arbdata [file]
+
+Prints each arbitrary data entry in the transaction as a hex dump, along with
+its specifier prefix. Recognized entries, such as Foundation unlock hash
+updates, are also decoded.
+`
+	annotateUsage = `Usage:
+    multisign annotate [file] [comment]
+
+Adds a comment to a transaction file, e.g. "approved by treasury 2024-06-01".
+Comments are stored alongside the transaction and displayed by the check
+command; they are not part of the transaction itself, and are never broadcast.
+`
+	fmtUsage = `Usage:
+    multisign fmt [flags] [file]
+
+Rewrites a transaction file in canonical form: signatures are sorted by the
+element they sign and their public key index, and the JSON is re-serialized with
+consistent field ordering and indentation. The transaction ID and the validity
+of every signature are verified to be unchanged before the file is written.
+Signatures are left in their original order if any of them covers only part of
+the transaction, since such signatures may refer to other signatures by index.
+`
+	feerateUsage = `Usage:
+    multisign feerate [flags] [file] [walrus server]
+
+Compares the transaction's fee rate with the rate recommended by the walrus
+server, classifies it as low, medium, or high, and prints guidance on how
+quickly it is likely to confirm. The rate is computed over the transaction's
+size once fully signed, assuming any missing signatures cover the whole
+transaction.
+`
+	pasteUsage = `Usage:
+    multisign paste [file]
+
+Reads a transaction pasted on stdin (end with Ctrl-D) and writes it to the
+specified file in canonical form. Unlike other commands, which parse files
+strictly, paste tolerates the artifacts of chat and email: surrounding text,
+code fences, quote markers, and line wrapping. The payload may be JSON or
+base64-encoded JSON.
+`
+	sizeUsage = `Usage:
+    multisign size [flags] [file]
+
+Prints how many bytes each section of a transaction (inputs, outputs, miner
+fees, arbitrary data, signatures, and so on) contributes to its encoded size,
+which determines the fee it needs. If the transaction is not yet fully signed,
+the size of its missing signatures is estimated and listed separately, so the
+total is the size of the final, fully-signed transaction.
+`
+	probeUsage = `Usage:
+    multisign probe [flags] [walrus server]
+
+Checks that a walrus server is reachable and responds to the endpoints that
+multisign uses: broadcast (required), and the chain tip and fee estimation
+(optional). No transaction is relayed. Connection failures are diagnosed, e.g.
+an untrusted server certificate or a rejected client certificate, so that they
+can be fixed before a real broadcast. Exits with status 5 if the server cannot
+be used to broadcast.
 `
 	broadcastUsage = `Usage:
-    multisign broadcast [file] [walrus server]
+    multisign broadcast [flags] [file...] [walrus server]
+
+Broadcasts the provided transaction. A summary of the transaction is printed,
+and the broadcast only proceeds after typing "yes" to confirm.
+
+The file may also contain a JSON array of transactions, and multiple files may
+be given, in which case the transactions are broadcast together as a single
+transaction set. Transactions that spend the outputs of other transactions in
+the set are ordered after them; a cycle of such dependencies is an error. If
+-sequential is set, each transaction is broadcast on its own, in dependency
+order, stopping at the first failure.
+
+Transactions whose fee rate is below -min-fee (1 mS per KB by default) are
+likely to be dropped by relays, and are not broadcast unless -force is given.
+
+If -wait is set, the command then waits until the transaction has that many
+confirmations, polling the siad API given by -wait-node, or until -wait-timeout
+elapses. Confirmations are counted from the block that included the
+transaction.
+`
+	confirmedUsage = `Usage:
+    multisign confirmed [file|transaction ID] [consensus.db]
+
+Checks whether a transaction has been confirmed, by looking up its outputs and
+inputs in the specified consensus set. If a transaction file is provided, the
+transaction is reported as pending if its inputs are still unspent. If only a
+transaction ID is provided, its inputs are unknown, so only its outputs can be
+checked; outputs that have since been spent are indistinguishable from an
+unconfirmed transaction.
 
-Broadcasts the provided transaction.
+The command exits with a non-zero status unless the transaction is confirmed.
+`
+	serveUsage = `Usage:
+    multisign serve [flags] [file]
+
+Runs a signing coordinator for the provided transaction. Co-signers submit
+signatures via HTTP; each signature is verified before being merged into the
+transaction file. The coordinator reports when enough signatures have been
+collected.
+
+Endpoints:
+    GET  /txn          returns the transaction
+    GET  /status       returns the current signing status
+    POST /signatures   submits a JSON array of signatures, or a signed copy of
+                       the transaction
+`
+	submitUsage = `Usage:
+    multisign submit [flags] [coordinator url]
+
+Fetches the transaction from a signing coordinator (see the serve command),
+signs it with the provided seed, and submits the new signature(s) to the
+coordinator.
 `
 )
 
@@ -91,24 +666,212 @@ func main() {
 	rootCmd.Usage = flagg.SimpleUsage(rootCmd, rootUsage)
 	seedCmd := flagg.New("seed", seedUsage)
 	pubkeyCmd := flagg.New("pubkey", pubkeyUsage)
+	pubkeyScheme := addSchemeFlag(pubkeyCmd)
+	keypubkeyCmd := flagg.New("keypubkey", keypubkeyUsage)
+	keysCmd := flagg.New("keys", keysUsage)
+	keysScheme := addSchemeFlag(keysCmd)
+	keysStart := keysCmd.Uint64("start", 0, "index of first key")
 	addrCmd := flagg.New("addr", addrUsage)
+	addrSiad := addrCmd.String("siad", "", "siad API address (e.g. localhost:9980) from which to fetch the pubkeys of wallet addresses")
+	addrSave := addrCmd.String("save", "", "store the UnlockConditions in the condition set under this label")
+	addrFormat := addrCmd.String("format", "json", `UnlockConditions format: "json", "siad", or "siad-hex"`)
+	addrQR := addrCmd.Bool("qr", false, "print the address as a QR code")
+	addrQRPNG := addrCmd.String("qr-png", "", "write the address as a QR code to this PNG file")
+	addrQROnly := addrCmd.Bool("qr-only", false, "print only the QR code, without the textual output")
+	ownsCmd := flagg.New("owns", ownsUsage)
+	ownsScan := addKeyScanFlags(ownsCmd)
+	ownsScheme := addSchemeFlag(ownsCmd)
+	descriptorCmd := flagg.New("descriptor", descriptorUsage)
+	descriptorScan := addKeyScanFlags(descriptorCmd)
+	descriptorScheme := addSchemeFlag(descriptorCmd)
+	descriptorOutput := addOutputFlag(descriptorCmd, "write the descriptor to this file instead of stdout")
+	descriptorSeed := descriptorCmd.Bool("seed", false, "when loading, re-derive the keys recorded as derived from a seed")
+	recoverConditionsCmd := flagg.New("recover-conditions", recoverConditionsUsage)
+	recoverConditionsTimelock := recoverConditionsCmd.Uint64("timelock", 0, "timelock of the address")
+	recoverConditionsSeed := recoverConditionsCmd.Uint64("seed", 0, "add the first n keys of a seed to the candidates")
+	recoverConditionsScheme := addSchemeFlag(recoverConditionsCmd)
+	recoverConditionsMax := recoverConditionsCmd.Uint64("max-attempts", 1e8, "give up after trying this many combinations")
+	recoverConditionsSave := recoverConditionsCmd.String("save", "", "store the recovered UnlockConditions in the condition set under this label")
+	proveCmd := flagg.New("prove", proveUsage)
+	proveOutput := addOutputFlag(proveCmd, "file to write a new proof to")
+	proveScan := addKeyScanFlags(proveCmd)
+	proveScheme := addSchemeFlag(proveCmd)
+	verifyProofCmd := flagg.New("verify-proof", verifyProofUsage)
+	contactsCmd := flagg.New("contacts", contactsUsage)
+	validateAddressCmd := flagg.New("validate-address", validateAddressUsage)
+	verifyAddressCmd := flagg.New("verify-address", verifyAddressUsage)
+	verifySeedCmd := flagg.New("verify-seed", verifySeedUsage)
+	verifySeedIndex := verifySeedCmd.Int("index", -1, "seed index of the key to check (default: scan the seed)")
+	verifySeedPosition := verifySeedCmd.Int("position", -1, "position of the seed's key among the address's keys (default: any)")
+	verifySeedScan := addKeyScanFlags(verifySeedCmd)
+	verifySeedScheme := addSchemeFlag(verifySeedCmd)
 	outputsCmd := flagg.New("outputs", outputsUsage)
+	outputsQuiet := outputsCmd.Bool("quiet", false, "don't print scan progress")
+	outputsSummary := outputsCmd.Bool("summary-json", false, "print only the count and total value, as JSON")
+	outputsRefs := outputsCmd.Bool("refs", false, "print output IDs as checksummed references")
+	outputsNode := outputsCmd.String("node", "", "walrus server to query for the chain tip, to check that the consensus set is synced")
+	outputsTLS := addTLSFlags(outputsCmd)
+	outputsExpected := outputsCmd.Uint64("expected-height", 0, "warn if the consensus set is behind this height")
+	nextsubsidyCmd := flagg.New("nextsubsidy", nextsubsidyUsage)
+	nextsubsidyBlockTime := nextsubsidyCmd.Duration("blocktime", time.Duration(types.BlockFrequency)*time.Second, "assumed average time between blocks")
+	subsidyvalueCmd := flagg.New("subsidyvalue", subsidyvalueUsage)
+	balanceCmd := flagg.New("balance", balanceUsage)
+	keyusageCmd := flagg.New("keyusage", keyusageUsage)
+	keyusageDepth := keyusageCmd.Uint64("depth", 1000, "number of seed keys to derive")
+	keyusageScheme := addSchemeFlag(keyusageCmd)
+	totalsCmd := flagg.New("totals", totalsUsage)
+	totalsFile := totalsCmd.String("file", "", "read addresses from this file, one per line")
+	totalsJSON := totalsCmd.Bool("json", false, "print totals as JSON")
+	changeCmd := flagg.New("change", changeUsage)
 	txnCmd := flagg.New("txn", txnUsage)
+	txnSpecFile := txnCmd.String("spec", "", "build the transaction from a JSON spec file instead of prompting")
+	txnAllowArb := txnCmd.Bool("allow-arbitrary-data", false, "allow raw arbitrary data entries (for advanced use only)")
+	txnUpdateIndex := txnCmd.Int("update-index", -1, "position in the transaction's arbitrary data at which to place a subsidy address update (default: last)")
+	txnPreview := txnCmd.Bool("preview", false, "print the transaction built from -spec without writing it")
+	txnSchema := txnCmd.Bool("schema", false, "print the JSON Schema of the spec format and exit")
+	txnOutput := addOutputFlag(txnCmd, "write the transaction to this file (instead of the positional file)")
+	addMaxFeeFlag(txnCmd)
+	rotateCmd := flagg.New("rotate", rotateUsage)
+	rotateIndex := rotateCmd.Int("index", -1, "position in the transaction's arbitrary data at which to attach the update (default: last)")
+	rotateReplace := rotateCmd.Bool("replace", false, "replace an existing Foundation update")
+	canisignCmd := flagg.New("canisign", canisignUsage)
+	canisignScan := addKeyScanFlags(canisignCmd)
+	canisignScheme := addSchemeFlag(canisignCmd)
 	signCmd := flagg.New("sign", signUsage)
+	signScan := addKeyScanFlags(signCmd)
+	signScheme := addSchemeFlag(signCmd)
+	signLabel := signCmd.String("label", "", "label (e.g. your name) to record for the signing key(s)")
+	signStrict := signCmd.Bool("strict", false, "refuse to sign transactions containing non-standard fields")
+	signUntilComplete := signCmd.Bool("until-complete", false, "keep prompting for seeds until the transaction is fully signed")
+	signKeep := signCmd.Bool("keep", false, "write to a new file named with the signing key index and a timestamp, leaving the input untouched")
+	signOutput := addOutputFlag(signCmd, "write the signed transaction to this file instead of modifying it in place")
+	signForce := signCmd.Bool("force", false, "overwrite the file even if it changed while signing")
+	addSanityFlags(signCmd)
 	checkCmd := flagg.New("check", checkUsage)
+	checkNode := checkCmd.String("node", "", "walrus server to query for the current height")
+	checkTLS := addTLSFlags(checkCmd)
+	checkHex := checkCmd.Bool("hex", false, "also print the binary encoding of the transaction")
+	addMaxFeeFlag(checkCmd)
+	checkCmd.BoolVar(&groupSignatures, "grouped", false, "list signatures grouped by input, with each input's threshold")
+	checkCmd.BoolVar(&diagnoseSignatures, "diagnose", true, "explain invalid signatures, e.g. by checking them at other heights")
+	checkConsensus := checkCmd.String("consensus", "", "consensus.db from which to look up input values")
+	checkHeights := checkCmd.String("heights", "", "comma-separated list of additional heights at which to validate the transaction")
+	exportCmd := flagg.New("export", exportUsage)
+	exportHeight := exportCmd.Uint64("height", 0, "validation height to pin in the bundle (default: the file's current height)")
+	exportOutput := addOutputFlag(exportCmd, "write the bundle to this file (instead of the positional bundle file)")
+	encodeCmd := flagg.New("encode", encodeUsage)
+	encodeOutput := addOutputFlag(encodeCmd, "write the encoded transaction to this file instead of stdout")
+	archiveCmd := flagg.New("archive", archiveUsage)
+	archiveConsensus := archiveCmd.String("consensus", "", "consensus.db from which to record the transaction's confirmation status")
+	manifestCmd := flagg.New("manifest", manifestUsage)
+	manifestFormat := manifestCmd.String("format", "json", `manifest format: "json" or "csv"`)
+	manifestOutput := addOutputFlag(manifestCmd, "write the manifest to this file instead of stdout")
+	statusCmd := flagg.New("status", statusUsage)
+	compareCmd := flagg.New("compare", compareUsage)
+	revalidateCmd := flagg.New("revalidate", revalidateUsage)
+	revalidateResign := revalidateCmd.Bool("resign", false, "replace invalidated signatures with new ones from a seed")
+	revalidateScan := addKeyScanFlags(revalidateCmd)
+	revalidateScheme := addSchemeFlag(revalidateCmd)
+	sanityCmd := flagg.New("sanity", sanityUsage)
+	addSanityFlags(sanityCmd)
+	collectCmd := flagg.New("collect", collectUsage)
+	collectOutput := addOutputFlag(collectCmd, "file to write the merged transaction to")
+	lintCmd := flagg.New("lint", lintUsage)
+	sighashCmd := flagg.New("sighash", sighashUsage)
+	importCmd := flagg.New("import", importUsage)
+	arbdataCmd := flagg.New("arbdata", arbdataUsage)
+	annotateCmd := flagg.New("annotate", annotateUsage)
+	fmtCmd := flagg.New("fmt", fmtUsage)
+	fmtOutput := addOutputFlag(fmtCmd, "write the formatted transaction to this file instead of modifying it in place")
+	confirmedCmd := flagg.New("confirmed", confirmedUsage)
+	pasteCmd := flagg.New("paste", pasteUsage)
+	feerateCmd := flagg.New("feerate", feerateUsage)
+	feerateTLS := addTLSFlags(feerateCmd)
+	sizeCmd := flagg.New("size", sizeUsage)
+	sizeJSON := sizeCmd.Bool("json", false, "print the breakdown as JSON")
+	probeCmd := flagg.New("probe", probeUsage)
+	probeTLS := addTLSFlags(probeCmd)
 	broadcastCmd := flagg.New("broadcast", broadcastUsage)
+	broadcastYes := broadcastCmd.Bool("yes", false, "skip the confirmation prompt")
+	broadcastTLS := addTLSFlags(broadcastCmd)
+	broadcastRetries := broadcastCmd.Int("retries", 3, "number of times to retry after a network error")
+	broadcastConsensus := broadcastCmd.String("consensus", "", "consensus.db to check that inputs are unspent before broadcasting")
+	broadcastStrict := broadcastCmd.Bool("strict", false, "abort if any input is spent (requires -consensus)")
+	broadcastDelay := broadcastCmd.Duration("retry-delay", time.Second, "delay before the first retry; doubled after each attempt")
+	broadcastWait := broadcastCmd.Int("wait", 0, "wait for this many confirmations after broadcasting (requires -wait-node)")
+	broadcastWaitNode := broadcastCmd.String("wait-node", "", "siad API address (e.g. localhost:9980) to poll for confirmations")
+	broadcastWaitTimeout := broadcastCmd.Duration("wait-timeout", 24*time.Hour, "give up waiting for confirmations after this long")
+	broadcastMinFee := types.SiacoinPrecision.Div64(1000) // 1 mS per KB
+	broadcastCmd.Var(currencyFlag{&broadcastMinFee}, "min-fee", "minimum fee rate, per KB (e.g. 1mS)")
+	broadcastForce := broadcastCmd.Bool("force", false, "broadcast even if the fee rate is below -min-fee")
+	broadcastSequential := broadcastCmd.Bool("sequential", false, "broadcast each transaction separately, in dependency order")
+	serveCmd := flagg.New("serve", serveUsage)
+	serveAddr := serveCmd.String("addr", ":8080", "address to listen on")
+	submitCmd := flagg.New("submit", submitUsage)
+	submitScan := addKeyScanFlags(submitCmd)
+	submitScheme := addSchemeFlag(submitCmd)
+	selftestCmd := flagg.New("selftest", selftestUsage)
+	verifyDerivationCmd := flagg.New("verify-derivation", verifyDerivationUsage)
+	fixtureCmd := flagg.New("fixture", fixtureUsage)
+	fixtureM := fixtureCmd.Int("m", 2, "number of signatures required")
+	fixtureN := fixtureCmd.Int("n", 3, "number of keys")
+	fixtureSigs := fixtureCmd.Int("sigs", 2, "number of signatures to attach")
 
 	cmd := flagg.Parse(flagg.Tree{
 		Cmd: rootCmd,
 		Sub: []flagg.Tree{
 			{Cmd: seedCmd},
 			{Cmd: pubkeyCmd},
+			{Cmd: keysCmd},
+			{Cmd: keypubkeyCmd},
 			{Cmd: addrCmd},
+			{Cmd: ownsCmd},
+			{Cmd: verifySeedCmd},
+			{Cmd: descriptorCmd},
+			{Cmd: recoverConditionsCmd},
+			{Cmd: proveCmd},
+			{Cmd: verifyProofCmd},
+			{Cmd: contactsCmd},
+			{Cmd: validateAddressCmd},
+			{Cmd: verifyAddressCmd},
 			{Cmd: outputsCmd},
+			{Cmd: nextsubsidyCmd},
+			{Cmd: subsidyvalueCmd},
+			{Cmd: balanceCmd},
+			{Cmd: keyusageCmd},
+			{Cmd: totalsCmd},
+			{Cmd: changeCmd},
 			{Cmd: txnCmd},
+			{Cmd: rotateCmd},
+			{Cmd: canisignCmd},
 			{Cmd: signCmd},
 			{Cmd: checkCmd},
+			{Cmd: exportCmd},
+			{Cmd: encodeCmd},
+			{Cmd: manifestCmd},
+			{Cmd: archiveCmd},
+			{Cmd: statusCmd},
+			{Cmd: compareCmd},
+			{Cmd: collectCmd},
+			{Cmd: revalidateCmd},
+			{Cmd: sanityCmd},
+			{Cmd: lintCmd},
+			{Cmd: sighashCmd},
+			{Cmd: importCmd},
+			{Cmd: arbdataCmd},
+			{Cmd: annotateCmd},
+			{Cmd: fmtCmd},
+			{Cmd: pasteCmd},
+			{Cmd: feerateCmd},
+			{Cmd: sizeCmd},
+			{Cmd: probeCmd},
 			{Cmd: broadcastCmd},
+			{Cmd: confirmedCmd},
+			{Cmd: serveCmd},
+			{Cmd: submitCmd},
+			{Cmd: selftestCmd},
+			{Cmd: verifyDerivationCmd},
+			{Cmd: fixtureCmd},
 		},
 	})
 	args := cmd.Args()
@@ -135,300 +898,2791 @@ func main() {
 		}
 		index, err := strconv.ParseUint(args[0], 10, 32)
 		check(err, "Invalid index")
-		fmt.Println(getSeed().PublicKey(index))
+		fmt.Println(getSeed(*pubkeyScheme).PublicKey(index))
+
+	case keypubkeyCmd:
+		var keyHex string
+		switch len(args) {
+		case 0:
+			keyHex = readSecret("Private key (hex)", "private key")
+		case 1:
+			fmt.Fprintln(os.Stderr, "WARNING: passing a private key on the command line may leak it via shell history or the process list.")
+			keyHex = args[0]
+		default:
+			cmd.Usage()
+			return
+		}
+		key, err := hex.DecodeString(strings.TrimSpace(keyHex))
+		check(err, "Invalid private key")
+		var sk ed25519.PrivateKey
+		switch len(key) {
+		case ed25519.SeedSize:
+			sk = ed25519.NewKeyFromSeed(key)
+		case ed25519.PrivateKeySize:
+			// the second half is the public key; make sure it matches the seed
+			sk = ed25519.NewKeyFromSeed(key[:ed25519.SeedSize])
+			if !bytes.Equal(sk[ed25519.SeedSize:], key[ed25519.SeedSize:]) {
+				log.Fatal("Invalid private key: its embedded public key does not match its seed half. The key is corrupt; do not use it.")
+			}
+		default:
+			log.Fatalf("Invalid private key: must be %v or %v bytes, got %v", ed25519.SeedSize, ed25519.PrivateKeySize, len(key))
+		}
+		fmt.Println(types.SiaPublicKey{
+			Algorithm: types.SignatureEd25519,
+			Key:       ed25519hash.ExtractPublicKey(sk),
+		})
+
+	case keysCmd:
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		n, err := strconv.ParseUint(args[0], 10, 32)
+		check(err, "Invalid number of keys")
+		seed := getSeed(*keysScheme)
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"index", "pubkey", "address"})
+		for i := *keysStart; i < *keysStart+n; i++ {
+			pk := seed.PublicKey(i)
+			w.Write([]string{strconv.FormatUint(i, 10), pk.String(), wallet.StandardAddress(pk).String()})
+		}
+		w.Flush()
+		check(w.Error(), "Could not write CSV")
 
 	case addrCmd:
 		if len(args) != 3 {
 			cmd.Usage()
 			return
 		}
-		timelock, err := strconv.ParseUint(args[0], 10, 64)
-		check(err, "Invalid timelock")
-		m, err := strconv.ParseUint(args[1], 10, 32)
-		check(err, "Invalid m")
-		var keys []types.SiaPublicKey
-		for _, s := range strings.Split(args[2], ",") {
+		var node *siadClient
+		if *addrSiad != "" {
+			node = &siadClient{addr: *addrSiad, password: siadPassword()}
+		}
+		uc := parseUnlockConditions(args[0], args[1], args[2], node)
+		if !*addrQROnly {
+			switch *addrFormat {
+			case "json":
+				js, _ := json.MarshalIndent(jsonUnlockConditions(uc), "", "  ")
+				fmt.Println(string(js))
+			case "siad":
+				js, _ := json.MarshalIndent(uc, "", "  ")
+				fmt.Println(string(js))
+			case "siad-hex":
+				fmt.Println(hex.EncodeToString(encoding.Marshal(uc)))
+			default:
+				log.Fatalf("Unknown format %q (must be \"json\", \"siad\", or \"siad-hex\")", *addrFormat)
+			}
+			fmt.Println(uc.UnlockHash())
+		}
+		if *addrQR || *addrQRPNG != "" || *addrQROnly {
+			q, err := newQRCode([]byte(uc.UnlockHash().String()))
+			check(err, "Could not encode address as QR code")
+			if *addrQR || (*addrQROnly && *addrQRPNG == "") {
+				q.writeTerminal(os.Stdout)
+			}
+			if *addrQRPNG != "" {
+				check(q.writePNG(*addrQRPNG, 8), "Could not write QR code")
+			}
+		}
+		if *addrSave != "" {
+			set := loadConditionSet()
+			set[*addrSave] = uc
+			set.save()
+			fmt.Printf("Saved UnlockConditions as %q\n", *addrSave)
+		}
+
+	case ownsCmd:
+		var uc types.UnlockConditions
+		switch len(args) {
+		case 1:
+			err := json.Unmarshal([]byte(args[0]), &uc)
+			check(err, "Invalid UnlockConditions")
+		case 4:
+			var addr types.UnlockHash
+			err := addr.LoadString(args[0])
+			check(err, "Invalid address")
+			uc = parseUnlockConditions(args[1], args[2], args[3], nil)
+			if uc.UnlockHash() != addr {
+				log.Fatal("Address does not match the provided timelock, m, and pubkeys")
+			}
+		default:
+			cmd.Usage()
+			return
+		}
+		checkOwnership(uc, getSeed(*ownsScheme), *ownsScan)
+
+	case contactsCmd:
+		book := loadAddressBook()
+		switch {
+		case len(args) == 0:
+			if len(book) == 0 {
+				fmt.Println("Address book is empty.")
+			}
+			for _, name := range book.names() {
+				fmt.Printf("%v: %v\n", name, book[name])
+			}
+		case len(args) == 3 && args[0] == "add":
 			var spk types.SiaPublicKey
-			err = spk.LoadString(s)
-			check(err, "Invalid pubkey")
-			keys = append(keys, spk)
+			check(spk.LoadString(args[2]), "Invalid pubkey")
+			if existing, ok := book.nameOf(spk); ok && existing != args[1] {
+				log.Fatalf("Pubkey is already in the address book as %q", existing)
+			}
+			book[args[1]] = spk
+			book.save()
+			fmt.Printf("Added %v.\n", args[1])
+		case len(args) == 2 && args[0] == "remove":
+			if _, ok := book[args[1]]; !ok {
+				log.Fatalf("No contact named %q", args[1])
+			}
+			delete(book, args[1])
+			book.save()
+			fmt.Printf("Removed %v.\n", args[1])
+		default:
+			cmd.Usage()
+			return
+		}
+
+	case validateAddressCmd:
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		var uc types.UnlockConditions
+		err := json.Unmarshal([]byte(args[0]), &uc)
+		check(err, "Invalid UnlockConditions")
+		if problems := unlockConditionsProblems(uc); len(problems) != 0 {
+			for _, p := range problems {
+				fmt.Println("Problem:", p)
+			}
+			os.Exit(1)
+		}
+		fmt.Printf("UnlockConditions are well-formed (%v-of-%v).\n", uc.SignaturesRequired, len(uc.PublicKeys))
+		fmt.Println("Address:", uc.UnlockHash())
+
+	case verifySeedCmd:
+		if len(args) != 2 {
+			cmd.Usage()
+			return
 		}
-		if m > uint64(len(keys)) {
-			log.Fatal("m cannot be greater than number of keys")
+		var uc types.UnlockConditions
+		err := json.Unmarshal([]byte(args[0]), &uc)
+		check(err, "Invalid UnlockConditions")
+		var expected types.UnlockHash
+		check(expected.LoadString(args[1]), "Invalid address")
+		seed := getSeed(*verifySeedScheme)
+		if err := verifySeed(uc, expected, seed, *verifySeedIndex, *verifySeedPosition, *verifySeedScan); err != nil {
+			fatal(err)
 		}
-		uc := types.UnlockConditions{
-			Timelock:           types.BlockHeight(timelock),
-			SignaturesRequired: m,
-			PublicKeys:         keys,
+
+	case descriptorCmd:
+		switch {
+		case len(args) == 2 && args[0] == "load":
+			d, err := readAddressDescriptor(args[1])
+			check(err, "Could not read descriptor")
+			uc, err := d.unlockConditions()
+			check(err, "Invalid descriptor")
+			js, _ := json.MarshalIndent(jsonUnlockConditions(uc), "", "  ")
+			fmt.Println(string(js))
+			fmt.Println(uc.UnlockHash())
+			if *descriptorSeed {
+				problems, checked := d.rederive(getSeed(*descriptorScheme), *descriptorScheme)
+				for _, p := range problems {
+					fmt.Println("MISMATCH:", p)
+				}
+				if len(problems) != 0 {
+					log.Fatal("Seed does not re-derive the descriptor's keys")
+				} else if checked == 0 {
+					fatal(withKind(errNoMatchingKeys, errors.New("Descriptor records no keys derived from this seed")))
+				}
+				fmt.Printf("Re-derived %v key(s) from the seed.\n", checked)
+			}
+		case len(args) == 1:
+			uc, ok := loadConditionSet()[args[0]]
+			if !ok {
+				check(json.Unmarshal([]byte(args[0]), &uc), "Invalid UnlockConditions")
+			}
+			d := newAddressDescriptor(uc, getSeed(*descriptorScheme), *descriptorScheme, *descriptorScan)
+			js, _ := json.MarshalIndent(d, "", "  ")
+			js = append(js, '\n')
+			if *descriptorOutput != "" {
+				check(ioutil.WriteFile(*descriptorOutput, js, 0666), "Could not write descriptor")
+			} else {
+				os.Stdout.Write(js)
+			}
+		default:
+			cmd.Usage()
+			return
+		}
+
+	case recoverConditionsCmd:
+		if len(args) != 2 && !(len(args) == 1 && *recoverConditionsSeed > 0) {
+			cmd.Usage()
+			return
+		}
+		var addr types.UnlockHash
+		check(addr.LoadString(args[0]), "Invalid address")
+		var candidates []types.SiaPublicKey
+		if len(args) == 2 {
+			candidates = parseUnlockConditions("0", "0", args[1], nil).PublicKeys
+		}
+		if *recoverConditionsSeed > 0 {
+			seed := getSeed(*recoverConditionsScheme)
+			for i := uint64(0); i < *recoverConditionsSeed; i++ {
+				candidates = append(candidates, seed.PublicKey(i))
+			}
+		}
+		// remove duplicates, which would only multiply the search
+		seen := make(map[string]bool)
+		unique := candidates[:0]
+		for _, spk := range candidates {
+			if !seen[spk.String()] {
+				seen[spk.String()] = true
+				unique = append(unique, spk)
+			}
+		}
+		candidates = unique
+		fmt.Printf("Searching %v candidate keys (at most %v combinations)...\n", len(candidates), *recoverConditionsMax)
+		uc, attempts, ok, err := recoverConditions(addr, types.BlockHeight(*recoverConditionsTimelock), candidates, *recoverConditionsMax)
+		if err == errSearchExhausted {
+			log.Fatalf("No match found within %v combinations; the search was not exhaustive. Narrow the candidate keys, or raise -max-attempts.", attempts)
+		} else if !ok {
+			log.Fatalf("No match found after trying all %v combinations. The address has a key not among the candidates, or a timelock other than %v.", attempts, *recoverConditionsTimelock)
 		}
+		fmt.Printf("Found a match after %v combinations (%v-of-%v):\n", attempts, uc.SignaturesRequired, len(uc.PublicKeys))
 		js, _ := json.MarshalIndent(jsonUnlockConditions(uc), "", "  ")
 		fmt.Println(string(js))
 		fmt.Println(uc.UnlockHash())
+		if *recoverConditionsSave != "" {
+			set := loadConditionSet()
+			set[*recoverConditionsSave] = uc
+			set.save()
+			fmt.Printf("Saved UnlockConditions as %q\n", *recoverConditionsSave)
+		}
 
-	case outputsCmd:
-		if len(args) != 1 {
+	case proveCmd:
+		var p controlProof
+		var filename string
+		switch {
+		case len(args) == 2 && *proveOutput != "":
+			uc, ok := loadConditionSet()[args[0]]
+			if !ok {
+				check(json.Unmarshal([]byte(args[0]), &uc), "Invalid UnlockConditions")
+			}
+			p = controlProof{
+				Version:          1,
+				Address:          uc.UnlockHash(),
+				UnlockConditions: uc,
+				Challenge:        args[1],
+			}
+			filename = *proveOutput
+		case len(args) == 1 && *proveOutput == "":
+			var err error
+			p, err = readControlProof(args[0])
+			check(err, "Could not read proof")
+			filename = args[0]
+		default:
+			cmd.Usage()
+			return
+		}
+		fmt.Println("Address:  ", p.Address)
+		fmt.Printf("Challenge: %q\n", p.Challenge)
+		required := int(p.UnlockConditions.SignaturesRequired)
+		for {
+			valid, _ := p.validSignatures()
+			fmt.Fprintf(os.Stderr, "Signatures: %v/%v\n", valid, required)
+			if valid >= required {
+				break
+			}
+			phrase := readSeedPhrase(*proveScheme)
+			if strings.TrimSpace(phrase) == "" {
+				break
+			}
+			seed, err := parseSeed(phrase, *proveScheme)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			if p.sign(seed, *proveScan) == 0 {
+				fmt.Fprintln(os.Stderr, "Seed controls none of the address's unsigned keys.")
+			}
+		}
+		writeControlProof(filename, p)
+		fmt.Println("Wrote proof to", filename)
+		if valid, _ := p.validSignatures(); valid < required {
+			fmt.Printf("Proof is INCOMPLETE: %v more signature(s) needed; pass %v to the remaining co-signers.\n", required-valid, filename)
+		} else {
+			fmt.Println("Proof is complete.")
+		}
+
+	case verifyProofCmd:
+		if len(args) != 2 {
 			cmd.Usage()
 			return
 		}
-		listOutputs(args[0])
+		p, err := readControlProof(args[0])
+		check(err, "Could not read proof")
+		fmt.Println("Address:  ", p.Address)
+		fmt.Printf("Challenge: %q\n", p.Challenge)
+		if p.Challenge != args[1] {
+			log.Fatalf("INVALID: proof is for challenge %q, not %q", p.Challenge, args[1])
+		}
+		valid, problems := p.validSignatures()
+		for _, problem := range problems {
+			fmt.Println("Problem:", problem)
+		}
+		fmt.Printf("Valid signatures: %v of %v required\n", valid, p.UnlockConditions.SignaturesRequired)
+		if uint64(valid) < p.UnlockConditions.SignaturesRequired || p.UnlockConditions.SignaturesRequired == 0 {
+			log.Fatal("INVALID: the proof does not demonstrate control of the address")
+		}
+		fmt.Println("VALID: the signers control the address.")
 
-	case txnCmd:
-		if len(args) != 1 {
+	case verifyAddressCmd:
+		if len(args) != 2 {
 			cmd.Usage()
 			return
 		}
-		txn := runTxnWizard()
-		writeTxn(args[0], txn)
-		fmt.Println("Wrote unsigned transaction to", args[0])
+		var uc types.UnlockConditions
+		err := json.Unmarshal([]byte(args[0]), &uc)
+		check(err, "Invalid UnlockConditions")
+		var expected types.UnlockHash
+		check(expected.LoadString(args[1]), "Invalid address")
+		book := loadAddressBook()
+		fmt.Println("Timelock:           ", uc.Timelock)
+		fmt.Printf("Signatures Required: %v of %v\n", uc.SignaturesRequired, len(uc.PublicKeys))
+		fmt.Println("Public Keys:")
+		for i, spk := range uc.PublicKeys {
+			fmt.Printf("  %v: %v\n", i, book.describe(spk))
+		}
+		fmt.Println("Computed Address:   ", uc.UnlockHash())
+		fmt.Println("Expected Address:   ", expected)
+		if uc.UnlockHash() != expected {
+			log.Fatal("MISMATCH: these UnlockConditions do not correspond to the expected address")
+		}
+		fmt.Println("Match: the UnlockConditions correspond to the expected address.")
 
-	case signCmd:
+	case outputsCmd:
 		if len(args) != 1 {
 			cmd.Usage()
 			return
 		}
-		txn := readTxn(args[0])
-		if err := txn.StandaloneValid(types.FoundationHardforkHeight + 1); err == nil {
-			fmt.Println("Transaction is already fully signed.")
-			return
-		} else if err != types.ErrMissingSignatures {
-			log.Fatalln("Transaction is invalid:", err)
+		tip := types.BlockHeight(*outputsExpected)
+		if *outputsNode != "" {
+			outputsTLS.configure()
+			info, err := walrus.NewClient(*outputsNode).ConsensusInfo()
+			check(err, "Could not query chain tip")
+			if info.Height > tip {
+				tip = info.Height
+			}
 		}
+		listOutputs(args[0], *outputsQuiet, *outputsSummary, *outputsRefs, tip)
 
-		if !sign(&txn, getSeed()) {
-			log.Fatal("Seed did not correspond to any missing signatures.")
+	case nextsubsidyCmd:
+		if len(args) != 1 {
+			cmd.Usage()
+			return
 		}
-		writeTxn(args[0], txn)
-		fmt.Println("Signature(s) added successfully.")
-		if txn.StandaloneValid(types.FoundationHardforkHeight+1) == nil {
-			fmt.Println("Transaction is now fully signed.")
+		var height types.BlockHeight
+		if h, err := strconv.ParseUint(args[0], 10, 64); err == nil {
+			height = types.BlockHeight(h)
+		} else {
+			db := openConsensusDB(args[0])
+			db.View(func(tx *bolt.Tx) error {
+				height = foundation.CurrentHeight(tx)
+				return nil
+			})
+			db.Close()
 		}
+		next := foundation.NextSubsidyHeight(height)
+		eta := time.Duration(next-height) * *nextsubsidyBlockTime
+		fmt.Println("Current height:     ", height)
+		fmt.Printf("Next subsidy height: %v (in %v blocks)\n", next, next-height)
+		fmt.Printf("Estimated time:      ~%.1f days (assuming %v per block)\n", eta.Hours()/24, *nextsubsidyBlockTime)
 
-	case checkCmd:
+	case subsidyvalueCmd:
 		if len(args) != 1 {
 			cmd.Usage()
 			return
 		}
-		checkTxn(readTxn(args[0]))
+		h, err := strconv.ParseUint(args[0], 10, 64)
+		check(err, "Invalid height")
+		height := types.BlockHeight(h)
+		value, ok := foundation.SubsidyValue(height)
+		if !ok {
+			next := foundation.NextSubsidyHeight(height)
+			if height < types.FoundationHardforkHeight {
+				log.Fatalf("No subsidy is created at height %v; the first subsidy is created at height %v", height, next)
+			}
+			log.Fatalf("No subsidy is created at height %v; the nearest subsidy heights are %v and %v", height, next-types.FoundationSubsidyFrequency, next)
+		}
+		fmt.Println("Height:", height)
+		fmt.Printf("Value:  %v (%v)\n", value.HumanString(), formatSC(value))
 
-	case broadcastCmd:
+	case balanceCmd:
 		if len(args) != 2 {
 			cmd.Usage()
 			return
 		}
-		txn := readTxn(args[0])
-		check(txn.StandaloneValid(types.FoundationHardforkHeight+1), "Transaction is standalone-invalid")
+		var addr types.UnlockHash
+		check(addr.LoadString(args[0]), "Invalid address")
+		db := openConsensusDB(args[1])
+		defer db.Close()
+		db.View(func(tx *bolt.Tx) error {
+			total, count := foundation.AddressBalance(tx, addr)
+			fmt.Printf("%v unspent output(s) totaling %v\n", count, formatSC(total))
+			return nil
+		})
 
-		err := walrus.NewClient(args[1]).Broadcast([]types.Transaction{txn})
-		check(err, "Broadcast failed")
-		fmt.Println("Transaction broadcast successfully.")
-		fmt.Println("Transaction ID:", txn.ID())
-	}
-}
+	case keyusageCmd:
+		if len(args) < 1 {
+			cmd.Usage()
+			return
+		}
+		ucs := make([]types.UnlockConditions, len(args)-1)
+		for i, s := range args[1:] {
+			check(json.Unmarshal([]byte(s), &ucs[i]), "Invalid UnlockConditions")
+		}
+		printKeyUsage(args[0], getSeed(*keyusageScheme), *keyusageDepth, ucs)
 
-type jsonUnlockConditions types.UnlockConditions
+	case totalsCmd:
+		if len(args) < 1 {
+			cmd.Usage()
+			return
+		}
+		addrStrs := args[1:]
+		if *totalsFile != "" {
+			contents, err := ioutil.ReadFile(*totalsFile)
+			check(err, "Could not read address file")
+			addrStrs = append(addrStrs, strings.Fields(string(contents))...)
+		}
+		if len(addrStrs) == 0 {
+			cmd.Usage()
+			return
+		}
+		addrs := make([]types.UnlockHash, len(addrStrs))
+		for i, s := range addrStrs {
+			check(addrs[i].LoadString(s), "Invalid address "+s)
+		}
+		printSubsidyTotals(args[0], addrs, *totalsJSON)
 
-func (uc jsonUnlockConditions) MarshalJSON() ([]byte, error) {
-	s := struct {
-		Timelock           types.BlockHeight `json:"timelock,omitempty"`
-		PublicKeys         []string          `json:"publicKeys"`
-		SignaturesRequired uint64            `json:"signaturesRequired"`
-	}{uc.Timelock, make([]string, len(uc.PublicKeys)), uc.SignaturesRequired}
-	for i := range s.PublicKeys {
-		s.PublicKeys[i] = uc.PublicKeys[i].Algorithm.String() + ":" + hex.EncodeToString(uc.PublicKeys[i].Key)
-	}
-	return json.Marshal(s)
-}
+	case changeCmd:
+		if len(args) != 3 {
+			cmd.Usage()
+			return
+		}
+		var amounts [3]types.Currency
+		for i, name := range []string{"input total", "output total", "fee"} {
+			if !parseCurrency(args[i], &amounts[i]) {
+				log.Fatalf("Invalid %v %q", name, args[i])
+			}
+		}
+		spent := amounts[1].Add(amounts[2])
+		if spent.Cmp(amounts[0]) > 0 {
+			log.Fatalf("Outputs and fee (%v) exceed inputs (%v) by %v", formatSC(spent), formatSC(amounts[0]), formatSC(spent.Sub(amounts[0])))
+		}
+		fmt.Println("Change:", formatSC(amounts[0].Sub(spent)))
 
-func check(err error, ctx string) {
-	if err != nil {
-		log.Fatalf("%v: %v", ctx, err)
+	case txnCmd:
+		if *txnSchema {
+			fmt.Print(specSchema)
+			return
+		}
+		if *txnPreview && *txnSpecFile == "" {
+			log.Fatal("-preview requires -spec")
+		}
+		if len(args) == 0 && *txnOutput != "" {
+			args = []string{*txnOutput}
+		} else if len(args) == 1 && *txnOutput != "" {
+			log.Fatal("Specify the output file either with -output or as an argument, not both")
+		}
+		if len(args) != 1 && !(*txnPreview && len(args) == 0) {
+			cmd.Usage()
+			return
+		}
+		f := txnFile{height: defaultHeight}
+		if *txnSpecFile != "" {
+			spec, err := readTxnSpec(*txnSpecFile)
+			check(err, "Could not read spec file")
+			if len(spec.ArbitraryData) != 0 && !*txnAllowArb {
+				log.Fatal("Spec contains raw arbitrary data; pass -allow-arbitrary-data to include it")
+			}
+			f.txn, err = buildTxn(spec, *txnUpdateIndex)
+			check(err, "Invalid spec")
+			f.ann.InputValues = specInputValues(spec)
+			if *txnPreview {
+				checkTxn(f)
+				return
+			}
+		} else {
+			for {
+				f.txn, f.ann.InputValues = runTxnWizard(*txnAllowArb, *txnUpdateIndex)
+				fmt.Println()
+				checkTxn(f)
+				fmt.Println()
+				resp := askReview()
+				if resp == "y" {
+					break
+				} else if resp == "n" {
+					log.Fatal("Transaction discarded.")
+				}
+			}
+		}
+		writeTxnFile(args[0], f)
+		fmt.Println("Wrote unsigned transaction to", args[0])
+
+	case rotateCmd:
+		switch {
+		case len(args) == 4 && args[0] == "propose":
+			var primary, failsafe types.UnlockHash
+			check(primary.LoadString(args[1]), "Invalid primary address")
+			check(failsafe.LoadString(args[2]), "Invalid failsafe address")
+			proposeUpdate(primary, failsafe, args[3])
+		case len(args) == 3 && args[0] == "attach":
+			attachUpdate(args[1], args[2], *rotateIndex, *rotateReplace)
+		case len(args) == 3 && args[0] == "preview":
+			previewUpdate(args[1], args[2])
+		default:
+			cmd.Usage()
+			return
+		}
+
+	case canisignCmd:
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		f := readTxnFile(args[0])
+		if controlled, _ := previewSigning(f.txn, getSeed(*canisignScheme), *canisignScan); controlled == 0 {
+			fatal(withKind(errNoMatchingKeys, errors.New("Seed controls none of the transaction's keys")))
+		}
+
+	case signCmd:
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		origHash, _ := fileHash(args[0])
+		f := readTxnFile(args[0])
+		txn := &f.txn
+		if txn.StandaloneValid(f.height) == nil {
+			fmt.Println("Transaction is already fully signed.")
+			return
+		} else if err := checkSignable(*txn, f.height, *signStrict); err != nil {
+			fatal(err)
+		}
+		if *signStrict {
+			if warnings := economicWarnings(*txn, addressNames()); len(warnings) != 0 {
+				for _, w := range warnings {
+					fmt.Println("WARNING:", w)
+				}
+				if ask("Sign anyway? Type 'yes' to confirm") != "yes" {
+					log.Fatal("Signing aborted.")
+				}
+			}
+		}
+		n := len(txn.TransactionSignatures)
+		if *signUntilComplete {
+			if *signLabel != "" {
+				log.Fatal("-label cannot be combined with -until-complete")
+			}
+			// keep prompting until the transaction is fully signed, or the
+			// user gives up
+			for txn.StandaloneValid(f.height) != nil {
+				phrase := readSeedPhrase(*signScheme)
+				if strings.TrimSpace(phrase) == "" {
+					break
+				}
+				seed, err := parseSeed(phrase, *signScheme)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					continue
+				}
+				if err := signTxn(txn, seed, *signScan, f.height); errors.Is(err, errNoMatchingKeys) {
+					fmt.Fprintln(os.Stderr, err)
+				} else if err != nil {
+					fatal(err)
+				}
+				have, required := signingProgress(*txn, f.height)
+				fmt.Fprintf(os.Stderr, "Signatures: %v/%v\n", have, required)
+			}
+			if len(txn.TransactionSignatures) == n {
+				fatal(withKind(errNoMatchingKeys, errors.New("No signatures were added.")))
+			}
+		} else if err := signTxn(txn, getSeed(*signScheme), *signScan, f.height); err != nil {
+			fatal(err)
+		}
+		if *signLabel != "" {
+			ucMap := unlockConditionsByID(*txn)
+			if f.ann.Signers == nil {
+				f.ann.Signers = make(map[string]string)
+			}
+			for _, sig := range txn.TransactionSignatures[n:] {
+				f.ann.Signers[ucMap[sig.ParentID].PublicKeys[sig.PublicKeyIndex].String()] = *signLabel
+			}
+		}
+		if *signKeep {
+			if *signOutput != "" {
+				log.Fatal("-keep cannot be combined with -output")
+			}
+			*signOutput = keepFilename(args[0], txn.TransactionSignatures[n:], time.Now())
+		}
+		// a transaction read from a URL can't be written back, so write it
+		// to stdout instead
+		msgs := os.Stdout
+		if *signOutput != "" {
+			writeTxnFile(*signOutput, f)
+			fmt.Println("Wrote signed transaction to", *signOutput)
+		} else if isURL(args[0]) {
+			os.Stdout.Write(encodeTxnFile(f))
+			msgs = os.Stderr
+		} else {
+			// guard against clobbering signatures added to the file by
+			// someone else while we were signing
+			if h, err := fileHash(args[0]); err == nil && h != origHash && !*signForce {
+				saved := keepFilename(args[0], txn.TransactionSignatures[n:], time.Now())
+				writeTxnFile(saved, f)
+				log.Fatalf("%v was modified while signing; refusing to overwrite it. Your signed copy was written to %v; merge its new signature(s) into the updated file, or pass -force to overwrite.", args[0], saved)
+			}
+			writeTxnFile(args[0], f)
+		}
+		fmt.Fprintln(msgs, "Signature(s) added successfully.")
+		if txn.StandaloneValid(f.height) == nil {
+			fmt.Fprintln(msgs, "Transaction is now fully signed.")
+		}
+
+	case checkCmd:
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		f := readTxnFile(args[0])
+		if *checkNode != "" {
+			checkTLS.configure()
+			if info, err := walrus.NewClient(*checkNode).ConsensusInfo(); err != nil {
+				fmt.Printf("Warning: could not query current height (%v); using height %v\n\n", err, f.height)
+			} else {
+				// validate as if the transaction were included in the next block
+				if f.bundle && info.Height+1 != f.height {
+					fmt.Printf("WARNING: VALIDATING AT HEIGHT %v, BUT BUNDLE IS PINNED TO HEIGHT %v.\n", info.Height+1, f.height)
+					fmt.Println("WARNING: SIGNATURES MADE AT THE PINNED HEIGHT MAY APPEAR INVALID.")
+					fmt.Println()
+				}
+				f.height = info.Height + 1
+			}
+		}
+		if *checkConsensus != "" {
+			lookupInputValues(*checkConsensus, &f)
+		}
+		if f.archive != nil {
+			printArchiveInfo(*f.archive)
+		}
+		checkTxn(f)
+		if *checkHeights != "" {
+			var heights []types.BlockHeight
+			for _, s := range strings.Split(*checkHeights, ",") {
+				h, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+				check(err, "Invalid height "+s)
+				heights = append(heights, types.BlockHeight(h))
+			}
+			fmt.Println()
+			printHeightMatrix(f.txn, heights)
+		}
+		if *checkHex {
+			fmt.Println()
+			printTxnEncoding(f.txn)
+		}
+
+	case exportCmd:
+		if len(args) == 1 && *exportOutput != "" {
+			args = append(args, *exportOutput)
+		} else if len(args) == 2 && *exportOutput != "" {
+			log.Fatal("Specify the bundle file either with -output or as an argument, not both")
+		}
+		if len(args) != 2 {
+			cmd.Usage()
+			return
+		}
+		f := readTxnFile(args[0])
+		if *exportHeight != 0 {
+			if f.bundle && types.BlockHeight(*exportHeight) != f.height {
+				fmt.Printf("WARNING: changing pinned height from %v to %v; existing signatures will be invalidated\n", f.height, *exportHeight)
+			}
+			f.height = types.BlockHeight(*exportHeight)
+		}
+		f.bundle = true
+		writeTxnFile(args[1], f)
+		fmt.Println("Wrote signing bundle to", args[1])
+
+	case encodeCmd:
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		f := readTxnFile(args[0])
+		if err := f.txn.StandaloneValid(f.height); err == types.ErrMissingSignatures {
+			fatal(withKind(errTxnInvalid, errors.New("Transaction is not fully signed; refusing to export it")))
+		} else if err != nil {
+			fatal(withKind(errTxnInvalid, fmt.Errorf("Transaction is invalid: %w", err)))
+		}
+		enc := hex.EncodeToString(encoding.Marshal(f.txn)) + "\n"
+		if *encodeOutput == "" {
+			fmt.Print(enc)
+			return
+		}
+		err := ioutil.WriteFile(*encodeOutput, []byte(enc), 0666)
+		check(err, "Could not write encoded transaction")
+		fmt.Println("Wrote encoded transaction to", *encodeOutput)
+
+	case manifestCmd:
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		f := readTxnFile(args[0])
+		manifest, err := encodeManifest(paymentManifest(f.txn, addressNames()), *manifestFormat)
+		check(err, "Could not encode manifest")
+		if *manifestOutput == "" {
+			os.Stdout.Write(manifest)
+			return
+		}
+		err = ioutil.WriteFile(*manifestOutput, manifest, 0666)
+		check(err, "Could not write manifest")
+		fmt.Println("Wrote payment manifest to", *manifestOutput)
+
+	case archiveCmd:
+		if len(args) != 2 {
+			cmd.Usage()
+			return
+		}
+		f := readTxnFile(args[0])
+		if err := f.txn.StandaloneValid(f.height); err != nil {
+			fmt.Printf("WARNING: archiving a transaction that is not valid (%v)\n", err)
+		}
+		writeArchive(args[1], f, *archiveConsensus)
+		fmt.Println("Wrote archive to", args[1])
+
+	case statusCmd:
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		f := readTxnFile(args[0])
+		if !printStatus(f.txn, f.height) {
+			os.Exit(1)
+		}
+
+	case compareCmd:
+		if len(args) < 2 {
+			cmd.Usage()
+			return
+		}
+		if !compareTxnFiles(args) {
+			os.Exit(1)
+		}
+
+	case collectCmd:
+		if len(args) == 0 {
+			cmd.Usage()
+			return
+		} else if *collectOutput == "" {
+			log.Fatal("-output is required")
+		}
+		filenames := expandTxnFilenames(args)
+		if len(filenames) == 0 {
+			log.Fatal("No transaction files found in ", strings.Join(args, ", "))
+		}
+		files := make([]txnFile, len(filenames))
+		for i, name := range filenames {
+			files[i] = readTxnFile(name)
+		}
+		merged, ok := collectSignatures(filenames, files)
+		if !ok {
+			fatal(withKind(errTxnInvalid, errors.New("Files do not all contain the same transaction; refusing to merge them.")))
+		}
+		writeTxnFile(*collectOutput, merged)
+		fmt.Println("Wrote merged transaction to", *collectOutput)
+		if !printStatus(merged.txn, merged.height) {
+			os.Exit(1)
+		}
+
+	case revalidateCmd:
+		if len(args) != 2 {
+			cmd.Usage()
+			return
+		}
+		f := readTxnFile(args[0])
+		h, err := strconv.ParseUint(args[1], 10, 64)
+		check(err, "Invalid height")
+		height := types.BlockHeight(h)
+
+		// compare each signature's validity at the old and new heights
+		ucMap := unlockConditionsByID(f.txn)
+		var kept []types.TransactionSignature
+		var invalidated int
+		for i, sig := range f.txn.TransactionSignatures {
+			before, after := validSignature(f.txn, i, ucMap, f.height), validSignature(f.txn, i, ucMap, height)
+			switch {
+			case after:
+				kept = append(kept, sig)
+			case before:
+				fmt.Printf("Signature on %v (key %v) is valid at height %v, but not at height %v; it must be regenerated.\n", sig.ParentID, sig.PublicKeyIndex, f.height, height)
+				invalidated++
+			default:
+				fmt.Printf("Signature on %v (key %v) is invalid.\n", sig.ParentID, sig.PublicKeyIndex)
+				invalidated++
+			}
+		}
+		if invalidated == 0 {
+			fmt.Printf("All %v signature(s) remain valid at height %v.\n", len(kept), height)
+		}
+
+		if *revalidateResign && invalidated > 0 {
+			f.txn.TransactionSignatures = kept
+			if err := signTxn(&f.txn, getSeed(*revalidateScheme), *revalidateScan, height); err != nil {
+				fatal(err)
+			}
+			f.height = height
+			writeTxnFile(args[0], f)
+			fmt.Println("Replaced invalidated signature(s) with new signature(s) at height", height)
+		}
+
+		if err := f.txn.StandaloneValid(height); err == nil {
+			fmt.Printf("Transaction is fully signed and valid at height %v.\n", height)
+		} else {
+			fmt.Printf("Transaction is not valid at height %v: %v\n", height, err)
+			os.Exit(1)
+		}
+
+	case sanityCmd:
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		f := readTxnFile(args[0])
+		warnings := economicWarnings(f.txn, addressNames())
+		for _, w := range warnings {
+			fmt.Println("WARNING:", w)
+		}
+		if len(warnings) != 0 {
+			log.Fatalf("%v warning(s) found; confirm each with the transaction's proposer before signing.", len(warnings))
+		}
+		fmt.Println("No economic warnings.")
+
+	case lintCmd:
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		f := readTxnFile(args[0])
+		problems := lintSignatures(f.txn)
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+		if len(problems) > 0 {
+			fatal(withKind(errLintProblems, fmt.Errorf("%v problem(s) found", len(problems))))
+		}
+		fmt.Println("No problems found.")
+
+	case sighashCmd:
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		f := readTxnFile(args[0])
+		fmt.Println("Transaction ID:", f.txn.ID())
+		fmt.Println("Height:        ", f.height)
+		for i, in := range f.txn.SiacoinInputs {
+			fmt.Printf("Input %v (%v):\n", i, in.ParentID)
+			for j, spk := range in.UnlockConditions.PublicKeys {
+				sigHash := wholeTxnSigHash(f.txn, crypto.Hash(in.ParentID), uint64(j), f.height)
+				fmt.Printf("  Key %v (%v): %x\n", j, spk, sigHash[:])
+			}
+		}
+
+	case importCmd:
+		if len(args) != 4 {
+			cmd.Usage()
+			return
+		}
+		f := readTxnFile(args[0])
+		var in types.SiacoinInput
+		if i, err := strconv.Atoi(args[1]); err == nil {
+			if i < 0 || i >= len(f.txn.SiacoinInputs) {
+				log.Fatal("Input index is out-of-bounds")
+			}
+			in = f.txn.SiacoinInputs[i]
+		} else {
+			id, err := parseOutputID(args[1])
+			check(err, "Invalid input")
+			found := false
+			for _, sci := range f.txn.SiacoinInputs {
+				if sci.ParentID == id {
+					in, found = sci, true
+				}
+			}
+			if !found {
+				log.Fatal("Transaction has no input with that ParentID")
+			}
+		}
+		keyIndex, err := strconv.ParseUint(args[2], 10, 64)
+		check(err, "Invalid key index")
+		if keyIndex >= uint64(len(in.UnlockConditions.PublicKeys)) {
+			log.Fatal("Key index is out-of-bounds")
+		}
+		sig, err := hex.DecodeString(strings.TrimSpace(args[3]))
+		check(err, "Invalid signature")
+		for _, ts := range f.txn.TransactionSignatures {
+			if ts.ParentID == crypto.Hash(in.ParentID) && ts.PublicKeyIndex == keyIndex {
+				log.Fatal("Transaction already has a signature from that key")
+			}
+		}
+		spk := in.UnlockConditions.PublicKeys[keyIndex]
+		sigHash := wholeTxnSigHash(f.txn, crypto.Hash(in.ParentID), keyIndex, f.height)
+		if spk.Algorithm != types.SignatureEd25519 || !ed25519hash.Verify(spk.Key, sigHash, sig) {
+			log.Fatalf("Signature is not a valid signature of %x by key %v", sigHash[:], spk)
+		}
+		f.txn.TransactionSignatures = append(f.txn.TransactionSignatures, types.TransactionSignature{
+			ParentID:       crypto.Hash(in.ParentID),
+			CoveredFields:  types.FullCoveredFields,
+			PublicKeyIndex: keyIndex,
+			Signature:      sig,
+		})
+		writeTxnFile(args[0], f)
+		fmt.Println("Signature added successfully.")
+		if f.txn.StandaloneValid(f.height) == nil {
+			fmt.Println("Transaction is now fully signed.")
+		}
+
+	case arbdataCmd:
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		printArbitraryData(readTxn(args[0]))
+
+	case annotateCmd:
+		if len(args) != 2 {
+			cmd.Usage()
+			return
+		}
+		f := readTxnFile(args[0])
+		f.ann.Comments = append(f.ann.Comments, args[1])
+		writeTxnFile(args[0], f)
+		fmt.Println("Comment added.")
+
+	case fmtCmd:
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		f := readTxnFile(args[0])
+		id := f.txn.ID()
+		before := countSignatures(f.txn, f.height)
+		if !sortSignatures(&f.txn) {
+			fmt.Println("Transaction contains partial signatures; leaving signature order unchanged.")
+		}
+		if f.txn.ID() != id {
+			log.Fatalf("Transaction ID changed while formatting (was %v, now %v); aborting without writing.", id, f.txn.ID())
+		}
+		for i, n := range countSignatures(f.txn, f.height) {
+			if n != before[i] {
+				log.Fatal("Signatures were invalidated while formatting; aborting without writing.")
+			}
+		}
+		if *fmtOutput != "" {
+			writeTxnFile(*fmtOutput, f)
+			fmt.Println("Wrote formatted transaction to", *fmtOutput)
+		} else {
+			writeTxnFile(args[0], f)
+			fmt.Println("Transaction file formatted.")
+		}
+
+	case confirmedCmd:
+		if len(args) != 2 {
+			cmd.Usage()
+			return
+		}
+		var id types.TransactionID
+		var txn *types.Transaction
+		if (*crypto.Hash)(&id).LoadString(args[0]) != nil {
+			t := readTxn(args[0])
+			id, txn = t.ID(), &t
+		}
+		if !checkConfirmed(args[1], id, txn) {
+			os.Exit(1)
+		}
+
+	case pasteCmd:
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		text, err := ioutil.ReadAll(os.Stdin)
+		check(err, "Could not read input")
+		f, err := extractPastedTxn(text)
+		check(withKind(errTxnInvalid, err), "Could not parse pasted transaction")
+		writeTxnFile(args[0], f)
+		fmt.Println("Wrote transaction", f.txn.ID(), "to", args[0])
+
+	case feerateCmd:
+		if len(args) != 2 {
+			cmd.Usage()
+			return
+		}
+		f := readTxnFile(args[0])
+		feerateTLS.configure()
+		rec, err := walrus.NewClient(args[1]).RecommendedFee()
+		check(err, "Could not get recommended fee")
+		printFeeRate(f, rec)
+
+	case sizeCmd:
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		printSizeBreakdown(sizeBreakdown(readTxnFile(args[0])), *sizeJSON)
+
+	case probeCmd:
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		probeTLS.configure()
+		if err := printProbeResults(args[0], probeWalrus(walrus.NewClient(args[0]))); err != nil {
+			fatal(err)
+		}
+		fmt.Println("The server can be used to broadcast.")
+
+	case broadcastCmd:
+		if len(args) < 2 {
+			cmd.Usage()
+			return
+		}
+		// validate each transaction at the height pinned by its bundle, if any
+		var txnSet []types.Transaction
+		heights := make(map[types.TransactionID]types.BlockHeight)
+		for _, filename := range args[:len(args)-1] {
+			set, height := readTxnSet(filename)
+			for _, txn := range set {
+				heights[txn.ID()] = height
+			}
+			txnSet = append(txnSet, set...)
+		}
+		txnSet, err := orderTxnSet(txnSet)
+		check(withKind(errTxnInvalid, err), "Could not order transaction set")
+		for _, txn := range txnSet {
+			height := heights[txn.ID()]
+			check(withKind(errTxnInvalid, txn.StandaloneValid(height)), fmt.Sprintf("Transaction %v is standalone-invalid at height %v", txn.ID(), height))
+		}
+		for _, txn := range txnSet {
+			if rate := feeRate(txnFile{txn: txn, height: heights[txn.ID()]}); rate.Mul64(1000).Cmp(broadcastMinFee) < 0 {
+				fmt.Printf("WARNING: transaction %v has a fee rate of %v/KB, below the minimum of %v/KB; relays are likely to drop it.\n", txn.ID(), rate.Mul64(1000).HumanString(), broadcastMinFee.HumanString())
+				if !*broadcastForce {
+					log.Fatal("Refusing to broadcast; raise the fee, or pass -force to broadcast anyway.")
+				}
+			}
+		}
+		if *broadcastConsensus != "" {
+			if spent := spentInputs(*broadcastConsensus, txnSet); len(spent) > 0 {
+				for _, id := range spent {
+					fmt.Println("WARNING: input", id, "does not exist or has already been spent, and is not created by another transaction in the set")
+				}
+				if *broadcastStrict {
+					log.Fatal("Transaction spends missing inputs; aborting.")
+				}
+			}
+		} else if *broadcastStrict {
+			log.Fatal("-strict requires -consensus")
+		} else if ext := externalInputs(txnSet); len(ext) > 0 {
+			fmt.Println("WARNING: the following inputs spend outputs that are not created by any transaction in the set, and cannot be verified without -consensus:")
+			for _, id := range ext {
+				fmt.Println("  ", id)
+			}
+			fmt.Println("If any of them is created by a transaction missing from the set, the broadcast will be rejected.")
+		}
+		if *broadcastWait > 0 && *broadcastWaitNode == "" {
+			log.Fatal("-wait requires -wait-node")
+		}
+		if !*broadcastYes {
+			for _, txn := range txnSet {
+				printBroadcastSummary(txn)
+			}
+			if ask("Broadcast this transaction set? Type 'yes' to confirm") != "yes" {
+				log.Fatal("Broadcast aborted.")
+			}
+		}
+
+		broadcastTLS.configure()
+		c := walrus.NewClient(args[len(args)-1])
+		if *broadcastSequential {
+			for i, txn := range txnSet {
+				err := broadcastWithRetry(c, []types.Transaction{txn}, *broadcastRetries, *broadcastDelay)
+				check(err, fmt.Sprintf("Broadcast of transaction %v (%v of %v) failed", txn.ID(), i+1, len(txnSet)))
+				if len(txnSet) > 1 {
+					fmt.Printf("Broadcast transaction %v of %v: %v\n", i+1, len(txnSet), txn.ID())
+				}
+			}
+		} else {
+			err := broadcastWithRetry(c, txnSet, *broadcastRetries, *broadcastDelay)
+			check(err, "Broadcast failed")
+		}
+		if len(txnSet) == 1 {
+			fmt.Println("Transaction broadcast successfully.")
+			fmt.Println("Transaction ID:", txnSet[0].ID())
+		} else {
+			fmt.Printf("%v transactions broadcast successfully.\n", len(txnSet))
+			fmt.Println("Transaction IDs:")
+			for _, txn := range txnSet {
+				fmt.Println("  ", txn.ID())
+			}
+		}
+		if *broadcastWait > 0 {
+			node := siadClient{addr: *broadcastWaitNode, password: siadPassword()}
+			err := waitForConfirmations(node, txnSet[len(txnSet)-1].ID(), *broadcastWait, *broadcastWaitTimeout)
+			check(err, "Could not confirm transaction")
+		}
+
+	case serveCmd:
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		runCoordinator(args[0], *serveAddr)
+
+	case submitCmd:
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		submitSignatures(args[0], *submitScan, *submitScheme)
+
+	case selftestCmd:
+		if len(args) != 0 {
+			cmd.Usage()
+			return
+		}
+		selfTest()
+
+	case verifyDerivationCmd:
+		if len(args) != 0 {
+			cmd.Usage()
+			return
+		}
+		if problems := derivationProblems(); len(problems) != 0 {
+			for _, p := range problems {
+				fmt.Println("MISMATCH:", p)
+			}
+			log.Fatal("KEY DERIVATION HAS CHANGED. DO NOT USE THIS BUILD TO SIGN; keys derived with it may not match your existing addresses.")
+		}
+		fmt.Printf("All %v derivation test vectors match.\n", len(derivationVectors))
+
+	case fixtureCmd:
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		f, seeds, err := buildFixture(*fixtureM, *fixtureN, *fixtureSigs)
+		check(err, "Could not build fixture")
+		writeTxnFile(args[0], f)
+		fmt.Println("Throwaway seeds (FOR TESTING ONLY):")
+		for i, seed := range seeds {
+			fmt.Printf("  %v: %v\n", i, seed)
+		}
+		fmt.Println("Wrote test fixture to", args[0])
+	}
+}
+
+type jsonUnlockConditions types.UnlockConditions
+
+func (uc jsonUnlockConditions) MarshalJSON() ([]byte, error) {
+	s := struct {
+		Timelock           types.BlockHeight `json:"timelock,omitempty"`
+		PublicKeys         []string          `json:"publicKeys"`
+		SignaturesRequired uint64            `json:"signaturesRequired"`
+	}{uc.Timelock, make([]string, len(uc.PublicKeys)), uc.SignaturesRequired}
+	for i := range s.PublicKeys {
+		s.PublicKeys[i] = uc.PublicKeys[i].Algorithm.String() + ":" + hex.EncodeToString(uc.PublicKeys[i].Key)
+	}
+	return json.Marshal(s)
+}
+
+// parseUnlockConditions parses the arguments of the addr command. Each key may
+// be a pubkey, a name in the address book, or, if node is non-nil, an address in
+// the node's wallet.
+func parseUnlockConditions(timelockStr, mStr, keysStr string, node *siadClient) types.UnlockConditions {
+	timelock, err := strconv.ParseUint(timelockStr, 10, 64)
+	check(err, "Invalid timelock")
+	m, err := strconv.ParseUint(mStr, 10, 32)
+	check(err, "Invalid m")
+	var keys []types.SiaPublicKey
+	var book addressBook
+	for _, s := range strings.Split(keysStr, ",") {
+		var spk types.SiaPublicKey
+		if err := spk.LoadString(s); err != nil {
+			// fall back to the address book, then the siad wallet
+			if book == nil {
+				book = loadAddressBook()
+			}
+			var addr types.UnlockHash
+			if named, ok := book[s]; ok {
+				spk = named
+			} else if node != nil && addr.LoadString(s) == nil {
+				spk, err = node.pubkey(addr)
+				check(err, "Could not fetch pubkey of "+s+" from siad")
+			} else if node != nil {
+				log.Fatalf("Invalid pubkey: %v is neither a pubkey, a name in the address book, nor an address", s)
+			} else {
+				log.Fatalf("Invalid pubkey: %v is neither a pubkey nor a name in the address book", s)
+			}
+		}
+		keys = append(keys, spk)
+	}
+	if m > uint64(len(keys)) {
+		log.Fatal("m cannot be greater than number of keys")
+	}
+	return types.UnlockConditions{
+		Timelock:           types.BlockHeight(timelock),
+		SignaturesRequired: m,
+		PublicKeys:         keys,
+	}
+}
+
+// unlockConditionsProblems returns a description of each way in which uc is not
+// a well-formed ed25519 multisig.
+func unlockConditionsProblems(uc types.UnlockConditions) []string {
+	var problems []string
+	seen := make(map[string]int)
+	for i, spk := range uc.PublicKeys {
+		if spk.Algorithm != types.SignatureEd25519 {
+			problems = append(problems, fmt.Sprintf("key %v has algorithm %q, not %q", i, spk.Algorithm, types.SignatureEd25519))
+		} else if len(spk.Key) != 32 {
+			problems = append(problems, fmt.Sprintf("key %v is %v bytes long, not 32", i, len(spk.Key)))
+		}
+		if j, ok := seen[spk.String()]; ok {
+			problems = append(problems, fmt.Sprintf("key %v is a duplicate of key %v", i, j))
+		} else {
+			seen[spk.String()] = i
+		}
+	}
+	if uc.SignaturesRequired == 0 {
+		problems = append(problems, "no signatures are required, so anyone can spend from this address")
+	} else if uc.SignaturesRequired > uint64(len(uc.PublicKeys)) {
+		problems = append(problems, fmt.Sprintf("%v signatures are required, but there are only %v keys", uc.SignaturesRequired, len(uc.PublicKeys)))
+	}
+	return problems
+}
+
+// A keyScan describes how many seed keys to derive when searching for a set of
+// pubkeys. Keys are scanned densely up to Depth; each match extends the scan to
+// at least Gap keys past the matching index, but never beyond Max keys.
+type keyScan struct {
+	Depth uint64
+	Gap   uint64
+	Max   uint64
+}
+
+// addOutputFlag adds an -output flag, and its shorthand -o, to cmd.
+func addOutputFlag(cmd *flag.FlagSet, usage string) *string {
+	output := new(string)
+	cmd.StringVar(output, "output", "", usage)
+	cmd.StringVar(output, "o", "", "shorthand for -output")
+	return output
+}
+
+func addKeyScanFlags(cmd *flag.FlagSet) *keyScan {
+	ks := new(keyScan)
+	cmd.Uint64Var(&ks.Depth, "depth", 10e3, "number of seed keys to scan")
+	cmd.Uint64Var(&ks.Gap, "gap", 1000, "keep scanning this many keys past each match")
+	cmd.Uint64Var(&ks.Max, "max", 1e6, "never scan more than this many keys")
+	return ks
+}
+
+// find returns the seed indices of any of the specified pubkeys that the seed
+// can derive.
+func (ks keyScan) find(seed signingSeed, pubkeys []types.SiaPublicKey) map[string]uint64 {
+	want := make(map[string]bool)
+	for _, spk := range pubkeys {
+		if spk.Algorithm == types.SignatureEd25519 {
+			want[string(spk.Key)] = true
+		}
+	}
+	indices := make(map[string]uint64)
+	end := ks.Depth
+	for i := uint64(0); i < end && i < ks.Max && len(indices) < len(want); i++ {
+		pk := seed.PublicKey(i).Key
+		if want[string(pk)] {
+			indices[string(pk)] = i
+			if i+ks.Gap >= end {
+				end = i + ks.Gap + 1
+			}
+		}
+	}
+	return indices
+}
+
+func checkOwnership(uc types.UnlockConditions, seed signingSeed, scan keyScan) {
+	indices := scan.find(seed, uc.PublicKeys)
+	book := loadAddressBook()
+	fmt.Println("Address:", uc.UnlockHash())
+	var owned int
+	for i, spk := range uc.PublicKeys {
+		if index, ok := indices[string(spk.Key)]; ok {
+			fmt.Printf("  Key %v (%v): seed index %v\n", i, book.describe(spk), index)
+			owned++
+		} else {
+			fmt.Printf("  Key %v (%v): not derived from seed\n", i, book.describe(spk))
+		}
+	}
+	if owned == 0 {
+		log.Fatalf("Seed does not control any of the address's %v public keys.", len(uc.PublicKeys))
+	}
+	fmt.Printf("Seed controls %v of %v public keys (%v signatures required).\n", owned, len(uc.PublicKeys), uc.SignaturesRequired)
+}
+
+// previewSigning prints, for each input of txn, the keys that seed controls,
+// without adding any signatures. It returns the number of keys the seed
+// controls, and how many of them have yet to sign.
+func previewSigning(txn types.Transaction, seed signingSeed, scan keyScan) (controlled, unsigned int) {
+	var pubkeys []types.SiaPublicKey
+	for _, in := range txn.SiacoinInputs {
+		pubkeys = append(pubkeys, in.UnlockConditions.PublicKeys...)
+	}
+	indices := scan.find(seed, pubkeys)
+	book := loadAddressBook()
+	for i, in := range txn.SiacoinInputs {
+		fmt.Printf("Input %v (%v):\n", i, in.ParentID)
+		before := controlled
+		for j, spk := range in.UnlockConditions.PublicKeys {
+			index, ok := indices[string(spk.Key)]
+			if !ok {
+				continue
+			}
+			controlled++
+			signed := false
+			for _, sig := range txn.TransactionSignatures {
+				signed = signed || (sig.ParentID == crypto.Hash(in.ParentID) && sig.PublicKeyIndex == uint64(j))
+			}
+			status := "can sign"
+			if signed {
+				status = "already signed"
+			} else {
+				unsigned++
+			}
+			fmt.Printf("  Key %v (%v): seed index %v, %v\n", j, book.describe(spk), index, status)
+		}
+		if controlled == before {
+			fmt.Println("  Seed controls none of this input's keys")
+		}
+	}
+	return controlled, unsigned
+}
+
+// verifySeed checks that seed, together with uc, reconstructs the expected
+// address, reporting which component differs if it does not. If index is
+// non-negative, only that seed index is checked; otherwise, the seed is scanned.
+// If position is non-negative, the seed's key must appear at that position in
+// uc.
+func verifySeed(uc types.UnlockConditions, expected types.UnlockHash, seed signingSeed, index, position int, scan keyScan) error {
+	if position >= len(uc.PublicKeys) {
+		return fmt.Errorf("position %v is out-of-bounds (address has %v keys)", position, len(uc.PublicKeys))
+	}
+	var problems []string
+	if addr := uc.UnlockHash(); addr != expected {
+		problems = append(problems, fmt.Sprintf("UnlockConditions: they hash to %v, not the expected address; the conditions themselves (timelock, m, or keys) differ from those the address was derived from", addr))
+	} else {
+		fmt.Println("UnlockConditions: match the expected address")
+	}
+
+	keys := uc.PublicKeys
+	if position >= 0 {
+		keys = keys[position : position+1]
+	}
+	indices := make(map[string]uint64)
+	if index >= 0 {
+		pk := seed.PublicKey(uint64(index))
+		for _, spk := range keys {
+			if spk.String() == pk.String() {
+				indices[string(pk.Key)] = uint64(index)
+			}
+		}
+		if len(indices) == 0 {
+			where := "any of the address's keys"
+			if position >= 0 {
+				where = fmt.Sprintf("key %v (%v)", position, keys[0])
+			}
+			problems = append(problems, fmt.Sprintf("Seed: index %v derives %v, which is not %v; the seed, its scheme, or the index differs", index, pk, where))
+		}
+	} else if indices = scan.find(seed, keys); len(indices) == 0 {
+		problems = append(problems, fmt.Sprintf("Seed: derives none of the address's keys within the first %v indices; the seed or its scheme differs, or the key lies deeper (see -depth)", scan.Depth))
+	}
+	for i, spk := range uc.PublicKeys {
+		if index, ok := indices[string(spk.Key)]; ok {
+			fmt.Printf("Seed: index %v derives key %v (%v)\n", index, i, spk)
+		}
+	}
+
+	if len(problems) != 0 {
+		for _, p := range problems {
+			fmt.Println("MISMATCH:", p)
+		}
+		err := errors.New("Seed does not reconstruct the expected address")
+		if len(indices) == 0 {
+			err = withKind(errNoMatchingKeys, err)
+		}
+		return err
+	}
+	fmt.Println("Seed reconstructs the expected address.")
+	return nil
+}
+
+// fileHash returns the hash of the contents of filename.
+func fileHash(filename string) (crypto.Hash, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return crypto.Hash{}, err
+	}
+	return crypto.HashBytes(data), nil
+}
+
+// keepFilename returns the name of the file that sign -keep writes sigs to,
+// derived from the input filename, the public key indices of sigs, and t.
+func keepFilename(filename string, sigs []types.TransactionSignature, t time.Time) string {
+	if isURL(filename) {
+		filename = path.Base(filename)
+	}
+	ext := filepath.Ext(filename)
+	var indices []string
+	seen := make(map[uint64]bool)
+	for _, sig := range sigs {
+		if !seen[sig.PublicKeyIndex] {
+			seen[sig.PublicKeyIndex] = true
+			indices = append(indices, strconv.FormatUint(sig.PublicKeyIndex, 10))
+		}
+	}
+	return fmt.Sprintf("%v.key%v.%v%v", strings.TrimSuffix(filename, ext), strings.Join(indices, "-"), t.UTC().Format("20060102T150405Z"), ext)
+}
+
+// checkSignable returns an error if txn should not be signed: if it is invalid
+// for any reason other than missing signatures, or, if strict is set, if it
+// contains non-standard fields.
+func checkSignable(txn types.Transaction, height types.BlockHeight, strict bool) error {
+	if err := txn.StandaloneValid(height); err != nil && err != types.ErrMissingSignatures {
+		return withKind(errTxnInvalid, fmt.Errorf("Transaction is invalid: %w", err))
+	}
+	if strict {
+		if fields := nonStandardFields(txn, true); len(fields) != 0 {
+			return withKind(errTxnInvalid, fmt.Errorf("Refusing to sign: transaction contains %v", strings.Join(fields, ", ")))
+		}
+	}
+	return nil
+}
+
+// signTxn is like sign, but returns an error if no signatures were added, or if
+// signing changed the transaction's ID.
+func signTxn(txn *types.Transaction, seed signingSeed, scan keyScan, height types.BlockHeight) error {
+	// signatures do not affect the transaction ID, so if it changed,
+	// something has gone badly wrong
+	id := txn.ID()
+	if !sign(txn, seed, scan, height) {
+		return withKind(errNoMatchingKeys, errors.New("Seed did not correspond to any missing signatures."))
+	} else if txn.ID() != id {
+		return fmt.Errorf("Transaction ID changed while signing (was %v, now %v); aborting without writing.", id, txn.ID())
+	}
+	return nil
+}
+
+// sign adds a signature to txn for each missing signature that seed can
+// provide. Signatures are computed at the specified height.
+func sign(txn *types.Transaction, seed signingSeed, scan keyScan, height types.BlockHeight) bool {
+	var pubkeys []types.SiaPublicKey
+	for _, in := range txn.SiacoinInputs {
+		pubkeys = append(pubkeys, in.UnlockConditions.PublicKeys...)
+	}
+	indices := scan.find(seed, pubkeys)
+
+	signed := false
+outer:
+	for _, in := range txn.SiacoinInputs {
+		for index, spk := range in.UnlockConditions.PublicKeys {
+			if keyIndex, ok := indices[string(spk.Key)]; ok {
+				// check for existing signature
+				for _, sig := range txn.TransactionSignatures {
+					if sig.ParentID == crypto.Hash(in.ParentID) && sig.PublicKeyIndex == uint64(index) {
+						continue outer
+					}
+				}
+
+				sigHash := wholeTxnSigHash(*txn, crypto.Hash(in.ParentID), uint64(index), height)
+				txn.TransactionSignatures = append(txn.TransactionSignatures, types.TransactionSignature{
+					ParentID:       crypto.Hash(in.ParentID),
+					CoveredFields:  types.FullCoveredFields,
+					PublicKeyIndex: uint64(index),
+					Signature:      ed25519hash.Sign(seed.SecretKey(keyIndex), sigHash),
+				})
+				signed = true
+			}
+		}
+	}
+	return signed
+}
+
+// wholeTxnSigHash returns the hash that a whole-transaction signature of txn
+// by the specified key must cover.
+func wholeTxnSigHash(txn types.Transaction, parentID crypto.Hash, pubkeyIndex uint64, height types.BlockHeight) crypto.Hash {
+	txn.TransactionSignatures = append(txn.TransactionSignatures[:len(txn.TransactionSignatures):len(txn.TransactionSignatures)], types.TransactionSignature{
+		ParentID:       parentID,
+		CoveredFields:  types.FullCoveredFields,
+		PublicKeyIndex: pubkeyIndex,
+	})
+	return txn.SigHash(len(txn.TransactionSignatures)-1, height)
+}
+
+// openConsensusDB opens the consensus database at consensusPath, exiting with
+// a helpful message if it cannot be opened.
+func openConsensusDB(consensusPath string) *bolt.DB {
+	db, err := foundation.OpenConsensusDB(consensusPath)
+	if err == foundation.ErrLocked {
+		log.Fatal("Could not open consensus.db: database is locked by another process. If siad is running, stop it or copy consensus.db elsewhere first.")
+	}
+	check(err, "Could not open consensus.db")
+	return db
+}
+
+// listOutputs prints each unspent subsidy output in the consensus set. If
+// summary is true, only the count and total value are printed, as JSON.
+func listOutputs(consensusPath string, quiet, summary, refs bool, tip types.BlockHeight) {
+	db := openConsensusDB(consensusPath)
+	defer db.Close()
+	if tip != 0 {
+		var height types.BlockHeight
+		db.View(func(tx *bolt.Tx) error {
+			height = foundation.CurrentHeight(tx)
+			return nil
+		})
+		warnIfBehind(height, tip)
+	}
+
+	var count int
+	var total types.Currency
+	if !summary {
+		fmt.Println("Outputs:")
+	}
+	db.View(func(tx *bolt.Tx) error {
+		currentHeight := foundation.CurrentHeight(tx)
+		lastProgress := time.Now()
+		for height := types.FoundationHardforkHeight; height < currentHeight; height += types.FoundationSubsidyFrequency {
+			if !quiet && time.Since(lastProgress) > time.Second {
+				fmt.Fprintf(os.Stderr, "Scanned up to height %v of %v\n", height, currentHeight)
+				lastProgress = time.Now()
+			}
+			if out, unspent := foundation.SubsidyOutputAt(tx, height); unspent {
+				count++
+				total = total.Add(out.Value)
+				if !summary {
+					id := out.ID.String()
+					if refs {
+						id = outputRef(out.ID)
+					}
+					fmt.Printf("Block %6v: %v %v (%v)\n", out.Height, id, out.UnlockHash, formatSC(out.Value))
+				}
+			}
+		}
+		return nil
+	})
+	if summary {
+		js, _ := json.MarshalIndent(struct {
+			Count         int            `json:"count"`
+			TotalSC       string         `json:"totalSC"`
+			TotalHastings types.Currency `json:"totalHastings"`
+		}{count, strings.Replace(strings.TrimSuffix(formatSC(total), " SC"), ",", "", -1), total}, "", "  ")
+		fmt.Println(string(js))
+	}
+}
+
+// warnIfBehind prints a warning to stderr if a consensus set at height appears
+// to be behind the chain tip. A lag of a single block is tolerated, since the
+// tip may have advanced in the meantime.
+func warnIfBehind(height, tip types.BlockHeight) {
+	if height+1 >= tip {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "WARNING: CONSENSUS SET IS AT HEIGHT %v, BUT THE CHAIN TIP IS AT HEIGHT %v (%v BLOCKS BEHIND).\n", height, tip, tip-height)
+	fmt.Fprintln(os.Stderr, "WARNING: THE NODE IS NOT FULLY SYNCED; RECENT SUBSIDIES MAY BE MISSING AND SPENT OUTPUTS MAY BE LISTED.")
+	fmt.Fprintln(os.Stderr)
+}
+
+// printKeyUsage reports which of the first depth standard addresses of seed,
+// and which of the multisig addresses ucs, hold unspent outputs.
+func printKeyUsage(consensusPath string, seed signingSeed, depth uint64, ucs []types.UnlockConditions) {
+	standard := make([]types.UnlockHash, depth)
+	addrs := make(map[types.UnlockHash]bool)
+	for i := range standard {
+		standard[i] = wallet.StandardAddress(seed.PublicKey(uint64(i)))
+		addrs[standard[i]] = true
+	}
+	for _, uc := range ucs {
+		addrs[uc.UnlockHash()] = true
+	}
+	counts := make(map[types.UnlockHash]int)
+	totals := make(map[types.UnlockHash]types.Currency)
+	db := openConsensusDB(consensusPath)
+	defer db.Close()
+	db.View(func(tx *bolt.Tx) error {
+		foundation.ForEachSiacoinOutput(tx, func(_ types.SiacoinOutputID, sco types.SiacoinOutput) {
+			if addrs[sco.UnlockHash] {
+				counts[sco.UnlockHash]++
+				totals[sco.UnlockHash] = totals[sco.UnlockHash].Add(sco.Value)
+			}
+		})
+		return nil
+	})
+
+	fmt.Println("Standard addresses:")
+	used, highest := 0, -1
+	for i, addr := range standard {
+		if counts[addr] > 0 {
+			fmt.Printf("  Key %v: %v (%v outputs, %v)\n", i, addr, counts[addr], formatSC(totals[addr]))
+			used++
+			highest = i
+		}
+	}
+	if used == 0 {
+		fmt.Printf("  None of the first %v addresses hold any outputs.\n", depth)
+	} else {
+		fmt.Printf("%v of the first %v addresses are in use; the highest is key %v.\n", used, depth, highest)
+	}
+
+	if len(ucs) > 0 {
+		fmt.Println()
+		fmt.Println("Multisig addresses:")
+		for _, uc := range ucs {
+			addr := uc.UnlockHash()
+			owned := len(keyScan{Depth: depth, Max: depth}.find(seed, uc.PublicKeys))
+			fmt.Printf("  %v (%v-of-%v, seed controls %v): %v outputs, %v\n", addr, uc.SignaturesRequired, len(uc.PublicKeys), owned, counts[addr], formatSC(totals[addr]))
+		}
+	}
+}
+
+type addressTotal struct {
+	Address types.UnlockHash `json:"address"`
+	Count   int              `json:"count"`
+	Total   types.Currency   `json:"total"`
+}
+
+func printSubsidyTotals(consensusPath string, addrs []types.UnlockHash, asJSON bool) {
+	totals := make([]addressTotal, len(addrs))
+	index := make(map[types.UnlockHash]int)
+	for i, addr := range addrs {
+		totals[i].Address = addr
+		index[addr] = i
+	}
+	err := foundation.ForEachUnspentSubsidy(consensusPath, func(out foundation.SubsidyOutput) error {
+		if i, ok := index[out.UnlockHash]; ok {
+			totals[i].Count++
+			totals[i].Total = totals[i].Total.Add(out.Value)
+		}
+		return nil
+	})
+	if err == foundation.ErrLocked {
+		log.Fatal("Could not open consensus.db: database is locked by another process. If siad is running, stop it or copy consensus.db elsewhere first.")
+	}
+	check(err, "Could not scan consensus.db")
+
+	var grandTotal types.Currency
+	for _, t := range totals {
+		grandTotal = grandTotal.Add(t.Total)
+	}
+	if asJSON {
+		js, _ := json.MarshalIndent(struct {
+			Addresses []addressTotal `json:"addresses"`
+			Total     types.Currency `json:"total"`
+		}{totals, grandTotal}, "", "  ")
+		fmt.Println(string(js))
+		return
+	}
+	for _, t := range totals {
+		fmt.Printf("%v: %v (%v outputs)\n", t.Address, formatSC(t.Total), t.Count)
+	}
+	fmt.Println("Total:", formatSC(grandTotal))
+}
+
+func ask(prompt string) (resp string) {
+	fmt.Print(prompt + ": ")
+	fmt.Scanln(&resp)
+	return
+}
+
+// currencyUnits maps the unit suffixes accepted by parseCurrency to their value
+// in hastings, expressed as a power of ten.
+var currencyUnits = []struct {
+	suffix string
+	exp    int64
+}{
+	{"pS", 12}, {"nS", 15}, {"uS", 18}, {"mS", 21}, {"SC", 24},
+	{"KS", 27}, {"MS", 30}, {"GS", 33}, {"TS", 36}, {"H", 0},
+}
+
+// parseCurrency parses s as a non-negative amount of SC. The amount may carry a
+// unit suffix, e.g. "10KS" or "500H"; thousands separators are accepted, so
+// amounts printed by formatSC can be pasted back in. Only plain decimals are
+// accepted (not fractions or exponents), and amounts must be a whole number of
+// hastings, so nothing is silently truncated.
+func parseCurrency(s string, c *types.Currency) bool {
+	s, ok := stripThousandsSeparators(strings.TrimSpace(s))
+	if !ok {
+		return false
+	}
+	exp := int64(24)
+	for _, u := range currencyUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s, exp = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.exp
+			break
+		}
+	}
+	if !isDecimal(s) {
+		return false
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return false
+	}
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(exp), nil)))
+	if !r.IsInt() {
+		return false
+	}
+	*c = types.NewCurrency(r.Num())
+	return true
+}
+
+// isDecimal reports whether s is a plain non-negative decimal number, e.g. "12"
+// or "0.5".
+func isDecimal(s string) bool {
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+		if fracPart == "" {
+			return false
+		}
+	}
+	if intPart == "" {
+		return false
+	}
+	for _, r := range intPart + fracPart {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// stripThousandsSeparators removes the commas from s, reporting whether they
+// are well-formed thousands separators: only in the integer part, with every
+// group after the first exactly three digits long. Thus "1,500" is accepted,
+// but "1,5" and "1,50,0" are not.
+func stripThousandsSeparators(s string) (string, bool) {
+	if !strings.Contains(s, ",") {
+		return s, true
+	}
+	end := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != ',' })
+	if end == -1 {
+		end = len(s)
+	}
+	if strings.Contains(s[end:], ",") {
+		return "", false
+	}
+	groups := strings.Split(s[:end], ",")
+	for i, g := range groups {
+		if len(g) == 0 || len(g) > 3 || (i > 0 && len(g) != 3) {
+			return "", false
+		}
+	}
+	return strings.Join(groups, "") + s[end:], true
+}
+
+// parsePercentage parses s, e.g. "12.5%", as a percentage of total, rounding
+// down to the nearest hasting.
+func parsePercentage(s string, total types.Currency, c *types.Currency) bool {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(strings.TrimSuffix(s, "%")))
+	if !ok || r.Sign() < 0 || r.Cmp(big.NewRat(100, 1)) > 0 {
+		return false
+	}
+	*c = total.MulRat(r.Quo(r, big.NewRat(100, 1)))
+	return true
+}
+
+// formatSC formats c as an exact SC amount, with thousands separators, e.g.
+// "1,234,567.5 SC".
+func formatSC(c types.Currency) string {
+	sc, rem := new(big.Int).QuoRem(c.Big(), types.SiacoinPrecision.Big(), new(big.Int))
+	digits := sc.String()
+	var grouped []byte
+	for i := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped = append(grouped, ',')
+		}
+		grouped = append(grouped, digits[i])
+	}
+	if rem.Sign() != 0 {
+		frac := rem.String()
+		frac = strings.Repeat("0", len(types.SiacoinPrecision.String())-1-len(frac)) + frac
+		grouped = append(grouped, '.')
+		grouped = append(grouped, strings.TrimRight(frac, "0")...)
+	}
+	return string(grouped) + " SC"
+}
+
+// runTxnWizard prompts for the details of a transaction, returning it along
+// with the value of each of its inputs, keyed by ParentID. If allowArb is set,
+// it also prompts for raw arbitrary data entries. Any subsidy address update
+// is placed among the arbitrary data as by foundation.PlaceUpdate with
+// updateIndex.
+func runTxnWizard(allowArb bool, updateIndex int) (txn types.Transaction, inputValues map[string]types.Currency) {
+	// inputs
+	fmt.Println("--- Inputs ---")
+	conditions := loadConditionSet()
+	inputValues = make(map[string]types.Currency)
+	var inputSum types.Currency
+	for {
+		idStr := ask("ID or output reference (or 'done')")
+		if idStr == "done" {
+			break
+		}
+		var in types.SiacoinInput
+		var err error
+		if in.ParentID, err = parseOutputID(idStr); err != nil {
+			fmt.Println("Invalid ID:", err)
+			continue
+		}
+		ucStr := ask("UnlockConditions (as JSON, no whitespace, or a saved label)")
+		if uc, ok := conditions[ucStr]; ok {
+			in.UnlockConditions = uc
+			fmt.Println("Using", uc.UnlockHash())
+		} else if json.Unmarshal([]byte(ucStr), &in.UnlockConditions) != nil {
+			fmt.Println("Invalid UnlockConditions")
+			continue
+		}
+		valueStr := ask("Value (in SC)")
+		var v types.Currency
+		if !parseCurrency(valueStr, &v) {
+			fmt.Println("Invalid value")
+			continue
+		}
+		txn.SiacoinInputs = append(txn.SiacoinInputs, in)
+		inputSum = inputSum.Add(v)
+		inputValues[in.ParentID.String()] = v
+	}
+	// outputs
+	fmt.Println("--- Outputs ---")
+	var outputSum types.Currency
+	for {
+		addrStr := ask("Address (or 'done')")
+		if addrStr == "done" {
+			fee := inputSum.Sub(outputSum)
+			if fee.Cmp(maxMinerFee) <= 0 {
+				break
+			}
+			fmt.Printf("WARNING: the remaining input value of %v would ALL be paid as a miner fee!\n", formatSC(fee))
+			fmt.Printf("WARNING: this exceeds the maximum fee of %v. Did you forget a change output?\n", formatSC(maxMinerFee))
+			if ask("Type 'yes' to pay this fee anyway, or anything else to add more outputs") == "yes" {
+				break
+			}
+			continue
+		}
+		var out types.SiacoinOutput
+		if out.UnlockHash.LoadString(addrStr) != nil {
+			fmt.Println("Invalid address")
+			continue
+		}
+		if out.UnlockHash == (types.UnlockHash{}) {
+			fmt.Println("Warning: this is the zero address; any coins sent to it will be unrecoverable")
+			if resp := strings.ToLower(ask("Send to this address anyway? [y/n]")); resp != "y" && resp != "yes" {
+				continue
+			}
+		}
+		amountStr := ask("Amount (in SC, or a percentage of inputs, e.g. 30%)")
+		if strings.HasSuffix(amountStr, "%") {
+			if !parsePercentage(amountStr, inputSum, &out.Value) {
+				fmt.Println("Invalid percentage")
+				continue
+			}
+			fmt.Printf("%v of %v is %v\n", amountStr, formatSC(inputSum), formatSC(out.Value))
+		} else if !parseCurrency(amountStr, &out.Value) {
+			fmt.Println("Invalid amount")
+			continue
+		}
+		txn.SiacoinOutputs = append(txn.SiacoinOutputs, out)
+		outputSum = outputSum.Add(out.Value)
+		if outputSum.Cmp(inputSum) > 0 {
+			log.Fatal("Invalid transaction: outputs exceed inputs")
+		}
+	}
+	fee := inputSum.Sub(outputSum)
+	if fee.IsZero() {
+		fmt.Println("Warning: outputs exactly equal inputs; miner fee will be zero")
+	} else if resp := strings.ToLower(ask(fmt.Sprintf("Remaining input value is %v. Split it into multiple miner fees? [y/n]", formatSC(fee)))); resp == "y" || resp == "yes" {
+		txn.MinerFees = askMinerFees(fee)
+	} else {
+		fmt.Printf("Remaining input value (%v) will be used as miner fee.\n", formatSC(fee))
+		txn.MinerFees = append(txn.MinerFees, fee)
+	}
+
+	var updateArb []byte
+	resp := strings.ToLower(ask("Include a subsidy address update in this transaction? [y/n]"))
+	for resp == "y" || resp == "yes" {
+		var update types.FoundationUnlockHashUpdate
+		if update.NewPrimary.LoadString(ask("New Primary Address")) != nil {
+			log.Fatal("Invalid address")
+		}
+		if update.NewFailsafe.LoadString(ask("New Failsafe Address")) != nil {
+			log.Fatal("Invalid address")
+		}
+		arb := foundation.UpdateArbitraryData(update.NewPrimary, update.NewFailsafe)
+		if !confirmUpdate(arb, update) {
+			continue
+		}
+		updateArb = arb
+		break
+	}
+
+	if allowArb {
+		fmt.Println("--- Arbitrary Data ---")
+		fmt.Println("WARNING: raw arbitrary data is recorded on-chain verbatim; only add entries you understand.")
+		for {
+			s := ask("Entry (hex, @file, or 'done')")
+			if s == "done" {
+				break
+			}
+			arb, err := parseArbitraryData(s)
+			if err != nil {
+				fmt.Println("Invalid entry:", err)
+				continue
+			}
+			txn.ArbitraryData = append(txn.ArbitraryData, arb)
+		}
+	}
+	if updateArb != nil {
+		if err := foundation.PlaceUpdate(&txn, updateArb, updateIndex); err != nil {
+			log.Fatal("Could not place subsidy address update: ", err)
+		}
+	}
+
+	return txn, inputValues
+}
+
+// askReview asks whether a reviewed transaction should be written, edited, or
+// discarded, returning "y", "e", or "n" respectively.
+func askReview() string {
+	for {
+		switch strings.ToLower(ask("Write this transaction? [y]es, [e]dit (start over), or [n]o (abort)")) {
+		case "y", "yes":
+			return "y"
+		case "e", "edit":
+			return "e"
+		case "n", "no":
+			return "n"
+		}
+	}
+}
+
+// askMinerFees prompts for a set of miner fees that sum to exactly total.
+func askMinerFees(total types.Currency) []types.Currency {
+	for {
+		var fees []types.Currency
+		var sum types.Currency
+		for sum.Cmp(total) < 0 {
+			fmt.Printf("%v of %v remaining.\n", formatSC(total.Sub(sum)), formatSC(total))
+			var fee types.Currency
+			if !parseCurrency(ask("Miner fee (in SC)"), &fee) || fee.IsZero() {
+				fmt.Println("Invalid amount")
+				continue
+			} else if sum.Add(fee).Cmp(total) > 0 {
+				fmt.Println("Fee exceeds remaining input value")
+				continue
+			}
+			fees = append(fees, fee)
+			sum = sum.Add(fee)
+		}
+		fmt.Println("Miner fees:")
+		for _, fee := range fees {
+			fmt.Println("  " + formatSC(fee))
+		}
+		if resp := strings.ToLower(ask("Is this correct? [y/n]")); resp == "y" || resp == "yes" {
+			return fees
+		}
+	}
+}
+
+// confirmationPollInterval is how often waitForConfirmations polls the node.
+const confirmationPollInterval = 30 * time.Second
+
+// waitForConfirmations polls node until the transaction with the specified ID
+// has the specified number of confirmations, reporting progress as it goes.
+// Confirmations are counted from the block that included the transaction.
+func waitForConfirmations(node siadClient, id types.TransactionID, confirmations int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	seen := false
+	for {
+		// check confirmation first, so that the including block is at or
+		// below the height we then search from
+		confirmed, err := node.confirmed(id)
+		if err != nil {
+			return fmt.Errorf("could not query node: %w", err)
+		}
+		height, err := node.height()
+		if err != nil {
+			return fmt.Errorf("could not query node: %w", err)
+		}
+
+		if !confirmed && seen {
+			fmt.Println("Transaction is no longer confirmed (reorg?); waiting for it to reappear.")
+		}
+		seen = confirmed
+		if confirmed {
+			// search only as deep as needed; if the transaction is buried
+			// deeper, it already has enough confirmations
+			depth, err := node.inclusionDepth(id, height, confirmations)
+			if err != nil {
+				return fmt.Errorf("could not query node: %w", err)
+			}
+			if depth > confirmations {
+				fmt.Printf("Height %v: at least %v confirmations\n", height, confirmations)
+			} else {
+				fmt.Printf("Height %v: %v of %v confirmations\n", height, depth, confirmations)
+			}
+			if depth >= confirmations {
+				fmt.Println("Transaction confirmed.")
+				return nil
+			}
+		} else {
+			fmt.Printf("Height %v: not yet confirmed\n", height)
+		}
+		if time.Now().Add(confirmationPollInterval).After(deadline) {
+			return fmt.Errorf("timed out after %v", timeout)
+		}
+		time.Sleep(confirmationPollInterval)
+	}
+}
+
+// txnConfirmed reports whether txn appears to have been confirmed: some of its
+// outputs are present or, if it has no outputs, all of its inputs are spent.
+func txnConfirmed(tx *bolt.Tx, id types.TransactionID, txn types.Transaction) bool {
+	if len(txn.SiacoinOutputs) == 0 {
+		for _, in := range txn.SiacoinInputs {
+			if _, ok := foundation.SiacoinOutput(tx, in.ParentID); ok {
+				return false
+			}
+		}
+		return len(txn.SiacoinInputs) > 0
+	}
+	for i := range txn.SiacoinOutputs {
+		outputID := types.SiacoinOutputID(crypto.HashAll(types.SpecifierSiacoinOutput, id, uint64(i)))
+		if _, ok := foundation.SiacoinOutput(tx, outputID); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkConfirmed reports whether the transaction with the specified ID has been
+// confirmed in the consensus set, printing a summary of the evidence. If txn is
+// nil, only the transaction's outputs are checked.
+func checkConfirmed(consensusPath string, id types.TransactionID, txn *types.Transaction) bool {
+	db := openConsensusDB(consensusPath)
+	defer db.Close()
+
+	var found, missing, spent, unspent int
+	var height types.BlockHeight
+	db.View(func(tx *bolt.Tx) error {
+		height = foundation.CurrentHeight(tx)
+		outputID := func(i uint64) types.SiacoinOutputID {
+			return types.SiacoinOutputID(crypto.HashAll(types.SpecifierSiacoinOutput, id, i))
+		}
+		if txn == nil {
+			// we don't know how many outputs there are, so count until one is
+			// missing
+			for i := uint64(0); ; i++ {
+				if _, ok := foundation.SiacoinOutput(tx, outputID(i)); !ok {
+					break
+				}
+				found++
+			}
+			return nil
+		}
+		for i := range txn.SiacoinOutputs {
+			if _, ok := foundation.SiacoinOutput(tx, outputID(uint64(i))); ok {
+				found++
+			} else {
+				missing++
+			}
+		}
+		for _, in := range txn.SiacoinInputs {
+			if _, ok := foundation.SiacoinOutput(tx, in.ParentID); ok {
+				unspent++
+			} else {
+				spent++
+			}
+		}
+		return nil
+	})
+
+	fmt.Println("Transaction ID:", id)
+	fmt.Println("Current height:", height)
+	switch {
+	case found > 0:
+		fmt.Printf("Confirmed: %v output(s) present in the consensus set.\n", found)
+		if missing > 0 {
+			fmt.Printf("(%v other output(s) have since been spent.)\n", missing)
+		}
+		return true
+	case txn == nil:
+		fmt.Println("Not found: none of the transaction's outputs are present in the consensus set.")
+		fmt.Println("The transaction has not been confirmed yet, or its outputs have since been spent.")
+	case unspent > 0:
+		fmt.Printf("Not confirmed yet: %v of %v input(s) are still unspent.\n", unspent, len(txn.SiacoinInputs))
+	case len(txn.SiacoinOutputs) == 0:
+		fmt.Println("Probably confirmed: all inputs have been spent, and the transaction has no outputs to check.")
+	default:
+		fmt.Println("Inputs have been spent, but none of the transaction's outputs are present.")
+		fmt.Println("Either the outputs have since been spent, or a different transaction spent the inputs.")
+	}
+	return false
+}
+
+// lookupInputValues fills in the value of any inputs of f whose values are not
+// already known, using the consensus set. Spent inputs are skipped.
+func lookupInputValues(consensusPath string, f *txnFile) {
+	db := openConsensusDB(consensusPath)
+	defer db.Close()
+	db.View(func(tx *bolt.Tx) error {
+		for _, in := range f.txn.SiacoinInputs {
+			if _, ok := f.ann.InputValues[in.ParentID.String()]; ok {
+				continue
+			}
+			if sco, ok := foundation.SiacoinOutput(tx, in.ParentID); ok {
+				if f.ann.InputValues == nil {
+					f.ann.InputValues = make(map[string]types.Currency)
+				}
+				f.ann.InputValues[in.ParentID.String()] = sco.Value
+			}
+		}
+		return nil
+	})
+}
+
+// externalInputs returns the IDs of the siacoin inputs in txnSet that spend
+// outputs not created within txnSet.
+func externalInputs(txnSet []types.Transaction) (ids []types.SiacoinOutputID) {
+	created := make(map[types.SiacoinOutputID]bool)
+	for _, txn := range txnSet {
+		for i := range txn.SiacoinOutputs {
+			created[txn.SiacoinOutputID(uint64(i))] = true
+		}
+	}
+	for _, txn := range txnSet {
+		for _, in := range txn.SiacoinInputs {
+			if !created[in.ParentID] {
+				ids = append(ids, in.ParentID)
+			}
+		}
+	}
+	return
+}
+
+// spentInputs returns the IDs of any siacoin inputs in txnSet that are not
+// present in the consensus set. Inputs that spend outputs created within txnSet
+// are ignored.
+func spentInputs(consensusPath string, txnSet []types.Transaction) (spent []types.SiacoinOutputID) {
+	db := openConsensusDB(consensusPath)
+	defer db.Close()
+	db.View(func(tx *bolt.Tx) error {
+		for _, id := range externalInputs(txnSet) {
+			if _, ok := foundation.SiacoinOutput(tx, id); !ok {
+				spent = append(spent, id)
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// orderTxnSet orders txnSet such that each transaction follows any transaction
+// in the set whose outputs it spends, preserving the original order otherwise.
+// It returns an error if the dependencies form a cycle.
+func orderTxnSet(txnSet []types.Transaction) ([]types.Transaction, error) {
+	creator := make(map[types.SiacoinOutputID]int)
+	for i, txn := range txnSet {
+		for j := range txn.SiacoinOutputs {
+			creator[txn.SiacoinOutputID(uint64(j))] = i
+		}
+	}
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(txnSet))
+	ordered := make([]types.Transaction, 0, len(txnSet))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("transaction %v depends on itself through a cycle of dependencies", txnSet[i].ID())
+		case visited:
+			return nil
+		}
+		state[i] = visiting
+		for _, in := range txnSet[i].SiacoinInputs {
+			if j, ok := creator[in.ParentID]; ok {
+				if err := visit(j); err != nil {
+					return err
+				}
+			}
+		}
+		state[i] = visited
+		ordered = append(ordered, txnSet[i])
+		return nil
+	}
+	for i := range txnSet {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// broadcastWithRetry broadcasts txnSet, retrying with exponential backoff if
+// the server cannot be reached. Errors returned by the server itself (e.g. an
+// invalid transaction) are not retried.
+func broadcastWithRetry(c *walrus.Client, txnSet []types.Transaction, retries int, delay time.Duration) error {
+	for attempt := 0; ; attempt++ {
+		err := c.Broadcast(txnSet)
+		var urlErr *url.Error
+		if err == nil || !errors.As(err, &urlErr) || attempt >= retries {
+			return withKind(errBroadcastFailed, err)
+		}
+		fmt.Fprintf(os.Stderr, "Broadcast failed (%v); retrying in %v...\n", err, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func printBroadcastSummary(txn types.Transaction) {
+	var outputSum, minerFee types.Currency
+	for _, out := range txn.SiacoinOutputs {
+		outputSum = outputSum.Add(out.Value)
+	}
+	for _, fee := range txn.MinerFees {
+		minerFee = minerFee.Add(fee)
 	}
+	fmt.Println("ID:          ", txn.ID())
+	fmt.Printf("Total Output: %v (%v)\n", outputSum.HumanString(), formatSC(outputSum))
+	fmt.Printf("Miner Fee:    %v (%v)\n", minerFee.HumanString(), formatSC(minerFee))
+	for _, arb := range txn.ArbitraryData {
+		if update, err := foundation.DecodeUpdate(arb); err == nil {
+			fmt.Println("New Primary: ", update.NewPrimary)
+			fmt.Println("New Failsafe:", update.NewFailsafe)
+		}
+	}
+	fmt.Println()
 }
 
-func readTxn(filename string) types.Transaction {
-	js, err := ioutil.ReadFile(filename)
-	check(err, "Could not read transaction file")
-	var txn types.Transaction
-	err = json.Unmarshal(js, &txn)
-	check(err, "Could not parse transaction file")
-	return txn
-}
-
-func writeTxn(filename string, txn types.Transaction) {
-	js, _ := json.MarshalIndent(walrus.JSONTransaction(txn), "", "  ")
-	js = append(js, '\n')
-	err := ioutil.WriteFile(filename, js, 0666)
-	check(err, "Could not write transaction to disk")
+// describeSignature describes the i'th signature of the transaction in f. If
+// the signature refers to a key, prefix is e.g. "Valid signature from key",
+// followed by the key (with its name or label, if any); otherwise, key is empty
+// and prefix explains why the signature is invalid. valid reports whether the
+// signature is valid. Any warnings about the signature are returned in notes.
+func describeSignature(f txnFile, i int, ucMap map[crypto.Hash]types.UnlockConditions, book addressBook) (prefix, key string, valid bool, notes []string) {
+	txn := f.txn
+	sig := txn.TransactionSignatures[i]
+	uc, ok := ucMap[sig.ParentID]
+	if !ok {
+		return fmt.Sprintf("INVALID signature on %v: no transaction element with that ID", sig.ParentID), "", false, nil
+	} else if sig.PublicKeyIndex >= uint64(len(uc.PublicKeys)) {
+		return fmt.Sprintf("INVALID signature on %v: public key index is out-of-bounds", sig.ParentID), "", false, nil
+	}
+	spk := uc.PublicKeys[sig.PublicKeyIndex]
+	key = spk.String()
+	name, known := book.nameOf(spk)
+	label, labelled := f.ann.Signers[key]
+	switch {
+	case known && labelled && label != name:
+		key += fmt.Sprintf(" (%v; labelled %q)", name, label)
+	case known:
+		key += " (" + name + ")"
+	case labelled:
+		key += " (" + label + ")"
+	}
+	sigHash := txn.SigHash(i, f.height)
+	if spk.Algorithm != types.SignatureEd25519 || !ed25519hash.Verify(spk.Key, sigHash, sig.Signature) {
+		if diagnoseSignatures && spk.Algorithm == types.SignatureEd25519 {
+			notes = diagnoseSignature(txn, i, spk, f.height)
+		}
+		return "INVALID signature from key", key, false, notes
+	}
+	if !sig.CoveredFields.WholeTransaction {
+		notes = append(notes, "(WARNING: signature does not cover whole transaction)")
+		// an uncovered Foundation update could be swapped out after signing
+		for arbIndex, arb := range txn.ArbitraryData {
+			covered := false
+			for _, j := range sig.CoveredFields.ArbitraryData {
+				covered = covered || j == uint64(arbIndex)
+			}
+			if foundation.IsUpdate(arb) && !covered {
+				notes = append(notes, "(WARNING: SIGNATURE DOES NOT COVER THE FOUNDATION UPDATE; IT COULD BE REPLACED WITHOUT INVALIDATING THIS SIGNATURE)")
+				break
+			}
+		}
+	}
+	return "Valid signature from key", key, true, notes
 }
 
-func getSeed() wallet.Seed {
-	fmt.Print("Seed: ")
-	phrase, err := term.ReadPassword(int(os.Stdin.Fd()))
-	check(err, "Could not read seed phrase")
-	fmt.Println()
-	seed, err := wallet.SeedFromPhrase(string(phrase))
-	check(err, "Invalid seed")
-	return seed
+// diagnoseSignature explains why the i'th signature of txn, by spk, is invalid
+// at height. The signature hash depends on the height only through replay
+// protection, so the signature is checked at a height in each other hardfork
+// era; if it is valid at one of them, the height was most likely misconfigured.
+func diagnoseSignature(txn types.Transaction, i int, spk types.SiaPublicKey, height types.BlockHeight) []string {
+	era := func(h types.BlockHeight) int {
+		switch {
+		case h >= types.FoundationHardforkHeight:
+			return 2
+		case h >= types.ASICHardforkHeight:
+			return 1
+		default:
+			return 0
+		}
+	}
+	for _, h := range []types.BlockHeight{0, types.ASICHardforkHeight, types.FoundationHardforkHeight} {
+		if era(h) != era(height) && ed25519hash.Verify(spk.Key, txn.SigHash(i, h), txn.TransactionSignatures[i].Signature) {
+			return []string{
+				fmt.Sprintf("(signature is valid at height %v, but not at %v, which is in a different hardfork era;", h, height),
+				" check the validation height, e.g. -node or a bundle's pinned height)",
+			}
+		}
+	}
+	return []string{"(signature is not valid at any height; it was made by a different key, is corrupt, or the transaction changed after signing)"}
 }
 
-func sign(txn *types.Transaction, seed wallet.Seed) bool {
-	// consider first 10k keys
-	keys := make(map[string]ed25519.PrivateKey)
-	for i := uint64(0); i < 10e3; i++ {
-		sk := seed.SecretKey(i)
-		keys[string(ed25519hash.ExtractPublicKey(sk))] = sk
+// printGroupedSignatures prints the signatures of the transaction in f grouped
+// by the element they sign, along with each element's signature threshold.
+// Valid signatures are counted once per key, up to the threshold; any beyond it
+// are reported separately, since they do not count toward validity.
+func printGroupedSignatures(f txnFile, ucMap map[crypto.Hash]types.UnlockConditions, book addressBook) {
+	txn := f.txn
+	var ids []crypto.Hash
+	for _, in := range txn.SiacoinInputs {
+		ids = append(ids, crypto.Hash(in.ParentID))
+	}
+	for _, in := range txn.SiafundInputs {
+		ids = append(ids, crypto.Hash(in.ParentID))
+	}
+	for _, rev := range txn.FileContractRevisions {
+		ids = append(ids, crypto.Hash(rev.ParentID))
+	}
+	fmt.Println("Signatures (by input):")
+	for _, id := range ids {
+		uc := ucMap[id]
+		var lines []string
+		validKeys := make(map[uint64]bool)
+		for i, sig := range txn.TransactionSignatures {
+			if sig.ParentID != id {
+				continue
+			}
+			prefix, key, valid, notes := describeSignature(f, i, ucMap, book)
+			if key == "" {
+				lines = append(lines, prefix)
+				continue
+			}
+			if valid {
+				validKeys[sig.PublicKeyIndex] = true
+			}
+			lines = append(lines, prefix+" "+key)
+			for _, n := range notes {
+				lines = append(lines, "  "+n)
+			}
+		}
+		counted, extra := uint64(len(validKeys)), uint64(0)
+		if counted > uc.SignaturesRequired {
+			counted, extra = uc.SignaturesRequired, counted-uc.SignaturesRequired
+		}
+		fmt.Printf("  %v: %v/%v valid signatures (%v-of-%v)", id, counted, uc.SignaturesRequired, uc.SignaturesRequired, len(uc.PublicKeys))
+		if extra > 0 {
+			fmt.Printf(", plus %v beyond the threshold", extra)
+		}
+		fmt.Println()
+		if len(lines) == 0 {
+			fmt.Println("    No signatures")
+		}
+		for _, l := range lines {
+			fmt.Println("    " + l)
+		}
+	}
+	var orphans []string
+	for i, sig := range txn.TransactionSignatures {
+		if _, ok := ucMap[sig.ParentID]; !ok {
+			prefix, _, _, _ := describeSignature(f, i, ucMap, book)
+			orphans = append(orphans, prefix)
+		}
+	}
+	if len(orphans) != 0 {
+		fmt.Println("  Signatures on unknown elements:")
+		for _, o := range orphans {
+			fmt.Println("    " + o)
+		}
 	}
+}
 
-	signed := false
-outer:
+// unlockConditionsByID maps the ID of each signable element in txn to its
+// UnlockConditions.
+func unlockConditionsByID(txn types.Transaction) map[crypto.Hash]types.UnlockConditions {
+	ucMap := make(map[crypto.Hash]types.UnlockConditions)
 	for _, in := range txn.SiacoinInputs {
-		for index, spk := range in.UnlockConditions.PublicKeys {
-			if key, ok := keys[string(spk.Key)]; ok {
-				// check for existing signature
-				for _, sig := range txn.TransactionSignatures {
-					if sig.ParentID == crypto.Hash(in.ParentID) && sig.PublicKeyIndex == uint64(index) {
-						continue outer
-					}
-				}
+		ucMap[crypto.Hash(in.ParentID)] = in.UnlockConditions
+	}
+	for _, in := range txn.SiafundInputs {
+		ucMap[crypto.Hash(in.ParentID)] = in.UnlockConditions
+	}
+	for _, rev := range txn.FileContractRevisions {
+		ucMap[crypto.Hash(rev.ParentID)] = rev.UnlockConditions
+	}
+	return ucMap
+}
 
-				wallet.AppendTransactionSignature(txn, types.TransactionSignature{
-					ParentID:       crypto.Hash(in.ParentID),
-					CoveredFields:  types.FullCoveredFields,
-					PublicKeyIndex: uint64(index),
-				}, key)
-				signed = true
-			}
+// lintSignatures returns a description of each signature in txn that refers to
+// an element or public key that does not exist.
+func lintSignatures(txn types.Transaction) []string {
+	ucMap := unlockConditionsByID(txn)
+	var problems []string
+	for i, sig := range txn.TransactionSignatures {
+		uc, ok := ucMap[sig.ParentID]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("Signature %v: orphan signature; no element with ID %v", i, sig.ParentID))
+		} else if sig.PublicKeyIndex >= uint64(len(uc.PublicKeys)) {
+			problems = append(problems, fmt.Sprintf("Signature %v: public key index %v is out-of-bounds for %v (which has %v keys)", i, sig.PublicKeyIndex, sig.ParentID, len(uc.PublicKeys)))
 		}
 	}
-	return signed
+	return problems
 }
 
-func foundationOutput(tx *bolt.Tx, height types.BlockHeight) (id types.SiacoinOutputID, sco types.SiacoinOutput, spent bool) {
-	var bid types.BlockID
-	encoding.Unmarshal(tx.Bucket([]byte("BlockPath")).Get(encoding.Marshal(height)), &bid)
-	id = bid.FoundationSubsidyID()
-	spent = encoding.Unmarshal(tx.Bucket([]byte("SiacoinOutputs")).Get(id[:]), &sco) != nil
-	return
+// validSignature reports whether the i'th signature of txn is a valid
+// signature by one of the keys of the element it refers to.
+func validSignature(txn types.Transaction, i int, ucMap map[crypto.Hash]types.UnlockConditions, height types.BlockHeight) bool {
+	sig := txn.TransactionSignatures[i]
+	uc, ok := ucMap[sig.ParentID]
+	if !ok || sig.PublicKeyIndex >= uint64(len(uc.PublicKeys)) {
+		return false
+	}
+	spk := uc.PublicKeys[sig.PublicKeyIndex]
+	sigHash := txn.SigHash(i, height)
+	return spk.Algorithm == types.SignatureEd25519 && ed25519hash.Verify(spk.Key, sigHash, sig.Signature)
 }
 
-func listOutputs(consensusPath string) {
-	_, err := os.Stat(consensusPath)
-	check(err, "Could not open consensus.db")
-	db, err := persist.OpenDatabase(persist.Metadata{
-		Header:  "Consensus Set Database",
-		Version: "0.5.0",
-	}, consensusPath)
-	check(err, "Could not open consensus.db")
-
-	fmt.Println("Outputs:")
-	db.View(func(tx *bolt.Tx) error {
-		var currentHeight types.BlockHeight
-		encoding.Unmarshal(tx.Bucket([]byte("BlockHeight")).Get([]byte("BlockHeight")), &currentHeight)
-		for height := types.FoundationHardforkHeight; height < currentHeight; height += types.FoundationSubsidyFrequency {
-			id, sco, spent := foundationOutput(tx, height)
-			if !spent {
-				fmt.Printf("Block %6v: %v %v (%v SC)\n", height, id, sco.UnlockHash, sco.Value.Div(types.SiacoinPrecision))
+// countSignatures returns the number of valid signatures, from distinct keys,
+// present for each siacoin input of txn.
+func countSignatures(txn types.Transaction, height types.BlockHeight) []uint64 {
+	ucMap := unlockConditionsByID(txn)
+	counts := make([]uint64, len(txn.SiacoinInputs))
+	for i, in := range txn.SiacoinInputs {
+		seen := make(map[uint64]bool)
+		for j, sig := range txn.TransactionSignatures {
+			if sig.ParentID == crypto.Hash(in.ParentID) && !seen[sig.PublicKeyIndex] && validSignature(txn, j, ucMap, height) {
+				seen[sig.PublicKeyIndex] = true
+				counts[i]++
 			}
 		}
-		return nil
-	})
-}
-
-func ask(prompt string) (resp string) {
-	fmt.Print(prompt + ": ")
-	fmt.Scanln(&resp)
-	return
+	}
+	return counts
 }
 
-func parseCurrency(s string, c *types.Currency) bool {
-	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
-	if !ok {
-		return false
+// sortSignatures sorts the signatures of txn by the position of the element
+// they sign, then by public key index. If any signature does not cover the
+// whole transaction, txn is left unchanged and sortSignatures returns false.
+func sortSignatures(txn *types.Transaction) bool {
+	for _, sig := range txn.TransactionSignatures {
+		if !sig.CoveredFields.WholeTransaction {
+			return false
+		}
+	}
+	pos := make(map[crypto.Hash]int)
+	for _, in := range txn.SiacoinInputs {
+		pos[crypto.Hash(in.ParentID)] = len(pos)
+	}
+	for _, in := range txn.SiafundInputs {
+		pos[crypto.Hash(in.ParentID)] = len(pos)
+	}
+	for _, rev := range txn.FileContractRevisions {
+		pos[crypto.Hash(rev.ParentID)] = len(pos)
 	}
-	*c = types.SiacoinPrecision.MulRat(r)
+	sigs := txn.TransactionSignatures
+	sort.SliceStable(sigs, func(i, j int) bool {
+		if pos[sigs[i].ParentID] != pos[sigs[j].ParentID] {
+			return pos[sigs[i].ParentID] < pos[sigs[j].ParentID]
+		}
+		return sigs[i].PublicKeyIndex < sigs[j].PublicKeyIndex
+	})
 	return true
 }
 
-func runTxnWizard() (txn types.Transaction) {
-	// inputs
-	fmt.Println("--- Inputs ---")
-	var inputSum types.Currency
-	for {
-		idStr := ask("ID (or 'done')")
-		if idStr == "done" {
-			break
-		}
-		var in types.SiacoinInput
-		if (*crypto.Hash)(&in.ParentID).LoadString(idStr) != nil {
-			fmt.Println("Invalid ID")
-			continue
+// signingProgress returns the number of valid signatures that txn has, and the
+// number it requires. Signatures beyond an input's threshold are not counted.
+func signingProgress(txn types.Transaction, height types.BlockHeight) (have, required int) {
+	for i, n := range countSignatures(txn, height) {
+		r := txn.SiacoinInputs[i].UnlockConditions.SignaturesRequired
+		if n > r {
+			n = r
 		}
-		ucStr := ask("UnlockConditions (as JSON, no whitespace)")
-		if json.Unmarshal([]byte(ucStr), &in.UnlockConditions) != nil {
-			fmt.Println("Invalid UnlockConditions")
+		have += int(n)
+		required += int(r)
+	}
+	return
+}
+
+// compareTxnFiles reports whether all of the specified files sign the same
+// transaction, printing the result for each file.
+func compareTxnFiles(filenames []string) bool {
+	// read transactions first, so that detached signatures can be checked
+	// against them
+	var ref *txnFile
+	var refName string
+	detached := make(map[string][]types.TransactionSignature)
+	files := make(map[string]txnFile)
+	for _, name := range filenames {
+		js, err := readFileOrURL(name)
+		check(err, "Could not read "+name)
+		if bytes.HasPrefix(bytes.TrimSpace(js), []byte("[")) {
+			var sigs []types.TransactionSignature
+			check(json.Unmarshal(js, &sigs), "Could not parse signatures in "+name)
+			detached[name] = sigs
 			continue
 		}
-		valueStr := ask("Value (in SC)")
-		var v types.Currency
-		if !parseCurrency(valueStr, &v) {
-			fmt.Println("Invalid value")
-			continue
+		f, err := parseTxnFile(js)
+		check(err, "Could not parse "+name)
+		files[name] = f
+		if ref == nil {
+			ref, refName = &f, name
 		}
-		txn.SiacoinInputs = append(txn.SiacoinInputs, in)
-		inputSum = inputSum.Add(v)
 	}
-	// outputs
-	fmt.Println("--- Outputs ---")
-	var outputSum types.Currency
-	for {
-		addrStr := ask("Address (or 'done')")
-		if addrStr == "done" {
-			break
-		}
-		var out types.SiacoinOutput
-		if out.UnlockHash.LoadString(addrStr) != nil {
-			fmt.Println("Invalid address")
+	if ref == nil {
+		log.Fatal("At least one file must contain a transaction")
+	}
+
+	match := true
+	refID := ref.txn.ID()
+	fmt.Printf("Reference: %v (%v)\n", refName, refID)
+	for _, name := range filenames {
+		if f, ok := files[name]; ok {
+			if id := f.txn.ID(); id != refID {
+				fmt.Printf("  MISMATCH  %v: transaction ID is %v\n", name, id)
+				match = false
+			} else if f.height != ref.height {
+				fmt.Printf("  MISMATCH  %v: validation height is %v, not %v\n", name, f.height, ref.height)
+				match = false
+			} else {
+				fmt.Printf("  OK        %v\n", name)
+			}
 			continue
 		}
-		amountStr := ask("Amount (in SC)")
-		if !parseCurrency(amountStr, &out.Value) {
-			fmt.Println("Invalid amount")
-			continue
+		// verify each detached signature against the reference transaction
+		txn := ref.txn
+		txn.TransactionSignatures = append(txn.TransactionSignatures[:0:0], detached[name]...)
+		ucMap := unlockConditionsByID(txn)
+		ok := true
+		for i := range txn.TransactionSignatures {
+			if !validSignature(txn, i, ucMap, ref.height) {
+				ok = false
+			}
 		}
-		txn.SiacoinOutputs = append(txn.SiacoinOutputs, out)
-		outputSum = outputSum.Add(out.Value)
-		if outputSum.Cmp(inputSum) > 0 {
-			log.Fatal("Invalid transaction: outputs exceed inputs")
+		if ok {
+			fmt.Printf("  OK        %v (%v detached signature(s))\n", name, len(txn.TransactionSignatures))
+		} else {
+			fmt.Printf("  MISMATCH  %v: signature(s) do not verify against the reference transaction\n", name)
+			match = false
 		}
 	}
-	fee := inputSum.Sub(outputSum)
-	if fee.IsZero() {
-		fmt.Println("Warning: outputs exactly equal inputs; miner fee will be zero")
+	if match {
+		fmt.Println("All files sign the same transaction.")
 	} else {
-		fmt.Printf("Remaining input value (%v SC) will be used as miner fee.\n", fee.Div(types.SiacoinPrecision))
-		txn.MinerFees = append(txn.MinerFees, fee)
+		fmt.Println("Files do NOT all sign the same transaction; do not merge them.")
 	}
+	return match
+}
 
-	resp := strings.ToLower(ask("Include a subsidy address update in this transaction? [y/n]"))
-	if resp == "y" || resp == "yes" {
-		var update types.FoundationUnlockHashUpdate
-		if update.NewPrimary.LoadString(ask("New Primary Address")) != nil {
-			log.Fatal("Invalid address")
+// printStatus prints the signing progress of txn, and reports whether it has
+// all of its required signatures.
+func printStatus(txn types.Transaction, height types.BlockHeight) bool {
+	fmt.Println("Transaction ID:", txn.ID())
+	var missing uint64
+	for i, have := range countSignatures(txn, height) {
+		in := txn.SiacoinInputs[i]
+		required := in.UnlockConditions.SignaturesRequired
+		fmt.Printf("  Input %v: %v/%v signatures\n", in.ParentID, have, required)
+		if have < required {
+			missing += required - have
 		}
-		if update.NewFailsafe.LoadString(ask("New Failsafe Address")) != nil {
-			log.Fatal("Invalid address")
+	}
+	if missing > 0 {
+		fmt.Printf("NEEDS %v MORE\n", missing)
+		return false
+	}
+	fmt.Println("FULLY SIGNED")
+	return true
+}
+
+func printArbitraryData(txn types.Transaction) {
+	if len(txn.ArbitraryData) == 0 {
+		fmt.Println("Transaction has no arbitrary data")
+		return
+	}
+	for i, arb := range txn.ArbitraryData {
+		fmt.Printf("Entry %v (%v bytes):\n", i, len(arb))
+		if len(arb) < types.SpecifierLen {
+			fmt.Println("  Specifier: none (entry is too short)")
+		} else {
+			var spec types.Specifier
+			copy(spec[:], arb)
+			name := strings.TrimRight(string(spec[:]), "\x00")
+			printable := true
+			for _, c := range name {
+				printable = printable && c >= 0x20 && c < 0x7f
+			}
+			if printable {
+				fmt.Printf("  Specifier: %q\n", name)
+			} else {
+				fmt.Printf("  Specifier: none (raw prefix %x)\n", spec[:])
+			}
+			if foundation.IsUpdate(arb) {
+				if update, err := foundation.DecodeUpdate(arb); err != nil {
+					fmt.Println("  Invalid Foundation unlock hash update:", err)
+				} else {
+					fmt.Println("  Foundation Unlock Hash Update:")
+					fmt.Println("    New Primary: ", update.NewPrimary)
+					fmt.Println("    New Failsafe:", update.NewFailsafe)
+				}
+			}
+		}
+		fmt.Print(hex.Dump(arb))
+		fmt.Println()
+	}
+}
+
+// printTxnEncoding prints the binary encoding of txn as hex, followed by the
+// encoding of each of its fields, in order.
+func printTxnEncoding(txn types.Transaction) {
+	enc := encoding.Marshal(txn)
+	fmt.Printf("Encoding (%v bytes):\n", len(enc))
+	fmt.Println(hex.EncodeToString(enc))
+	fmt.Println()
+	fmt.Println("Fields:")
+	for _, f := range txnFields(txn) {
+		b := encoding.Marshal(f.v)
+		fmt.Printf("  %-22v (%5v bytes): %x\n", f.name, len(b), b)
+	}
+}
+
+// A txnField is a named field of a transaction.
+type txnField struct {
+	name  string
+	count int
+	v     interface{}
+}
+
+// txnFields returns the fields of txn, in encoding order, along with the
+// number of elements in each.
+func txnFields(txn types.Transaction) []txnField {
+	return []txnField{
+		{"SiacoinInputs", len(txn.SiacoinInputs), txn.SiacoinInputs},
+		{"SiacoinOutputs", len(txn.SiacoinOutputs), txn.SiacoinOutputs},
+		{"FileContracts", len(txn.FileContracts), txn.FileContracts},
+		{"FileContractRevisions", len(txn.FileContractRevisions), txn.FileContractRevisions},
+		{"StorageProofs", len(txn.StorageProofs), txn.StorageProofs},
+		{"SiafundInputs", len(txn.SiafundInputs), txn.SiafundInputs},
+		{"SiafundOutputs", len(txn.SiafundOutputs), txn.SiafundOutputs},
+		{"MinerFees", len(txn.MinerFees), txn.MinerFees},
+		{"ArbitraryData", len(txn.ArbitraryData), txn.ArbitraryData},
+		{"TransactionSignatures", len(txn.TransactionSignatures), txn.TransactionSignatures},
+	}
+}
+
+// nonStandardFields returns a description of each unexpected kind of content
+// in txn. If includeArbData is true, unrecognized or invalid arbitrary data is
+// also reported.
+func nonStandardFields(txn types.Transaction, includeArbData bool) []string {
+	var fields []string
+	if includeArbData {
+		var unrecognized, invalid bool
+		for _, arb := range txn.ArbitraryData {
+			if !foundation.IsUpdate(arb) {
+				unrecognized = true
+			} else if _, err := foundation.DecodeUpdate(arb); err != nil {
+				invalid = true
+			}
+		}
+		if unrecognized {
+			fields = append(fields, "unrecognized arbitrary data")
 		}
-		txn.ArbitraryData = append(txn.ArbitraryData, encoding.MarshalAll(types.SpecifierFoundation, update))
+		if invalid {
+			fields = append(fields, "invalid Foundation unlock hash update")
+		}
+	}
+	if len(txn.FileContracts) != 0 {
+		fields = append(fields, "file contract(s)")
+	}
+	if len(txn.FileContractRevisions) != 0 {
+		fields = append(fields, "file contract revision(s)")
+	}
+	if len(txn.StorageProofs) != 0 {
+		fields = append(fields, "storage proof(s)")
+	}
+	if len(txn.SiafundInputs) != 0 {
+		fields = append(fields, "siafund input(s)")
+	}
+	if len(txn.SiafundOutputs) != 0 {
+		fields = append(fields, "siafund output(s)")
 	}
+	return fields
+}
+
+// diagnoseSignatures causes checkTxn to explain why each invalid signature is
+// invalid, where possible.
+var diagnoseSignatures = true
+
+// groupSignatures causes checkTxn to list signatures grouped by the element they
+// sign, rather than in a single flat list.
+var groupSignatures bool
 
-	return txn
+// printHeightMatrix prints whether txn is valid at each of the specified
+// heights, along with its signing progress and whether the timelocks of its
+// inputs have expired.
+func printHeightMatrix(txn types.Transaction, heights []types.BlockHeight) {
+	var maxTimelock types.BlockHeight
+	for _, in := range txn.SiacoinInputs {
+		if in.UnlockConditions.Timelock > maxTimelock {
+			maxTimelock = in.UnlockConditions.Timelock
+		}
+	}
+	fmt.Printf("%-10v %-10v %-10v %v\n", "Height", "Valid", "Signed", "Timelocks")
+	for _, height := range heights {
+		valid := "yes"
+		if err := txn.StandaloneValid(height); err != nil {
+			valid = "NO"
+		}
+		have, required := signingProgress(txn, height)
+		timelocks := "expired"
+		if height < maxTimelock {
+			timelocks = fmt.Sprintf("LOCKED until %v", maxTimelock)
+			valid = "NO"
+		}
+		fmt.Printf("%-10v %-10v %-10v %v\n", height, valid, fmt.Sprintf("%v/%v", have, required), timelocks)
+	}
 }
 
-func checkTxn(txn types.Transaction) {
+func checkTxn(f txnFile) {
+	txn, ann := f.txn, f.ann
 	fmt.Println("Transaction summary:")
 	fmt.Println()
 	fmt.Println("ID:   ", txn.ID())
-	if err := txn.StandaloneValid(types.FoundationHardforkHeight + 1); err == nil {
+	if f.bundle {
+		fmt.Println("Height:", f.height)
+	}
+	if err := txn.StandaloneValid(f.height); err == nil {
 		fmt.Println("Valid: Yes")
 	} else {
 		fmt.Printf("Valid: No (%v)\n", err)
@@ -436,9 +3690,17 @@ func checkTxn(txn types.Transaction) {
 	fmt.Println()
 
 	fmt.Println("Inputs:")
+	var inputSum types.Currency
+	inputValuesKnown := true
 	for _, in := range txn.SiacoinInputs {
 		fmt.Println("  ID:  ", in.ParentID)
 		fmt.Println("  Addr:", in.UnlockConditions.UnlockHash())
+		if v, ok := ann.InputValues[in.ParentID.String()]; ok {
+			fmt.Printf("  Value: %v (%v)\n", v.HumanString(), formatSC(v))
+			inputSum = inputSum.Add(v)
+		} else {
+			inputValuesKnown = false
+		}
 	}
 	fmt.Println()
 	fmt.Println("Outputs:")
@@ -450,20 +3712,38 @@ func checkTxn(txn types.Transaction) {
 				break
 			}
 		}
-		fmt.Printf("  %8v %v %v\n", out.Value.HumanString(), dest, out.UnlockHash)
+		fmt.Printf("  %8v (%v) %v %v\n", out.Value.HumanString(), formatSC(out.Value), dest, out.UnlockHash)
 	}
 	fmt.Println()
 	var minerFee types.Currency
 	for _, fee := range txn.MinerFees {
 		minerFee = minerFee.Add(fee)
 	}
-	fmt.Println("Miner Fee:", minerFee.HumanString())
+	fmt.Printf("Miner Fee: %v (%v)\n", minerFee.HumanString(), formatSC(minerFee))
+	if minerFee.Cmp(maxMinerFee) > 0 {
+		fmt.Printf("WARNING: miner fee exceeds the maximum of %v; check that no change output is missing\n", formatSC(maxMinerFee))
+	}
+	if inputValuesKnown && len(txn.SiacoinInputs) > 0 {
+		// the fee actually paid is whatever the outputs don't claim
+		var outputSum types.Currency
+		for _, out := range txn.SiacoinOutputs {
+			outputSum = outputSum.Add(out.Value)
+		}
+		if outputSum.Add(minerFee).Cmp(inputSum) > 0 {
+			fmt.Printf("WARNING: outputs and miner fees exceed the input value (%v); the transaction will be rejected\n", formatSC(inputSum))
+		} else if actual := inputSum.Sub(outputSum); actual.Cmp(minerFee) != 0 {
+			fmt.Printf("WARNING: inputs minus outputs is %v, but declared miner fees are only %v\n", formatSC(actual), formatSC(minerFee))
+			fmt.Println("WARNING: the transaction will be rejected unless its outputs claim the remaining value")
+		}
+	} else if len(txn.SiacoinInputs) > 0 {
+		fmt.Println("(Input values unknown; pass -consensus to look them up and verify the fee.)")
+	}
 	fmt.Println()
 	// check for update
 	for _, arb := range txn.ArbitraryData {
-		if bytes.HasPrefix(arb, types.SpecifierFoundation[:]) {
-			var update types.FoundationUnlockHashUpdate
-			if err := encoding.Unmarshal(arb[types.SpecifierLen:], &update); err != nil {
+		if foundation.IsUpdate(arb) {
+			update, err := foundation.DecodeUpdate(arb)
+			if err != nil {
 				fmt.Println("WARNING: transaction contains invalid Foundation unlock hash update")
 				continue
 			}
@@ -475,58 +3755,46 @@ func checkTxn(txn types.Transaction) {
 			fmt.Println("WARNING: transaction contains unrecognized arbitrary data")
 		}
 	}
-	// check for other non-standard fields
-	if len(txn.FileContracts) != 0 {
-		fmt.Println("WARNING: transaction contains file contract(s)")
-	}
-	if len(txn.FileContractRevisions) != 0 {
-		fmt.Println("WARNING: transaction contains file contract revision(s)")
-	}
-	if len(txn.StorageProofs) != 0 {
-		fmt.Println("WARNING: transaction contains storage proof(s)")
-	}
-	if len(txn.SiafundInputs) != 0 {
-		fmt.Println("WARNING: transaction contains siafund input(s)")
+	for _, out := range txn.SiacoinOutputs {
+		if out.UnlockHash == (types.UnlockHash{}) {
+			fmt.Println("WARNING: transaction sends coins to the zero address; they will be unrecoverable")
+			break
+		}
 	}
-	if len(txn.SiafundOutputs) != 0 {
-		fmt.Println("WARNING: transaction contains siafund output(s)")
+	// check for other non-standard fields
+	for _, field := range nonStandardFields(txn, false) {
+		fmt.Println("WARNING: transaction contains", field)
 	}
 
 	// validate signatures
-	ucMap := make(map[crypto.Hash]types.UnlockConditions)
-	for _, in := range txn.SiacoinInputs {
-		ucMap[crypto.Hash(in.ParentID)] = in.UnlockConditions
-	}
-	for _, in := range txn.SiafundInputs {
-		ucMap[crypto.Hash(in.ParentID)] = in.UnlockConditions
-	}
-	for _, rev := range txn.FileContractRevisions {
-		ucMap[crypto.Hash(rev.ParentID)] = rev.UnlockConditions
-	}
-	fmt.Println("Signatures:")
-	for i, sig := range txn.TransactionSignatures {
-		uc, ok := ucMap[sig.ParentID]
-		if !ok {
-			fmt.Printf("  INVALID signature on %v: no transaction element with that ID\n", sig.ParentID)
-			continue
-		} else if sig.PublicKeyIndex >= uint64(len(uc.PublicKeys)) {
-			fmt.Printf("  INVALID signature on %v: public key index is out-of-bounds\n", sig.ParentID)
-			continue
-		}
-		spk := uc.PublicKeys[sig.PublicKeyIndex]
-		sigHash := txn.SigHash(i, types.FoundationHardforkHeight+1)
-		if spk.Algorithm != types.SignatureEd25519 || !ed25519hash.Verify(spk.Key, sigHash, sig.Signature) {
-			fmt.Println("  INVALID signature from key", spk)
-			fmt.Println("                          on", sig.ParentID)
-			continue
+	book := loadAddressBook()
+	ucMap := unlockConditionsByID(txn)
+	if groupSignatures {
+		printGroupedSignatures(f, ucMap, book)
+	} else {
+		fmt.Println("Signatures:")
+		for i, sig := range txn.TransactionSignatures {
+			prefix, key, _, notes := describeSignature(f, i, ucMap, book)
+			if key == "" {
+				fmt.Println("  " + prefix)
+				continue
+			}
+			fmt.Println("  "+prefix, key)
+			fmt.Println(strings.Repeat(" ", len(prefix))+"on", sig.ParentID)
+			for _, n := range notes {
+				fmt.Println("    " + n)
+			}
 		}
-		fmt.Println("  Valid signature from key", spk)
-		fmt.Println("                        on", sig.ParentID)
-		if !sig.CoveredFields.WholeTransaction {
-			fmt.Println("    (WARNING: signature does not cover whole transaction)")
+		if len(txn.TransactionSignatures) == 0 {
+			fmt.Println("  Transaction has no signatures")
 		}
 	}
-	if len(txn.TransactionSignatures) == 0 {
-		fmt.Println("  Transaction has no signatures")
+
+	if len(ann.Comments) != 0 {
+		fmt.Println()
+		fmt.Println("Comments:")
+		for _, c := range ann.Comments {
+			fmt.Println("  " + c)
+		}
 	}
 }
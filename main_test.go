@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"go.sia.tech/siad/types"
+)
+
+func TestParseCurrency(t *testing.T) {
+	tests := []struct {
+		s    string
+		want types.Currency
+		ok   bool
+	}{
+		{"15", types.SiacoinPrecision.Mul64(15), true},
+		{"1,500", types.SiacoinPrecision.Mul64(1500), true},
+		{"1,234,567.5 SC", types.SiacoinPrecision.Mul64(2469135).Div64(2), true},
+		{"1,000KS", types.SiacoinPrecision.Mul64(1000000), true},
+		{"1,000H", types.NewCurrency64(1000), true},
+		{"1,5", types.Currency{}, false},
+		{"1,50,0", types.Currency{}, false},
+		{"1,5000", types.Currency{}, false},
+		{",500", types.Currency{}, false},
+		{"1,", types.Currency{}, false},
+		{"1.5,000", types.Currency{}, false},
+		{"-1", types.Currency{}, false},
+		{"1/3", types.Currency{}, false},
+		{"1e-30", types.Currency{}, false},
+		{"0.5H", types.Currency{}, false},
+		{".5", types.Currency{}, false},
+		{"5.", types.Currency{}, false},
+	}
+	for _, test := range tests {
+		var c types.Currency
+		if ok := parseCurrency(test.s, &c); ok != test.ok {
+			t.Errorf("parseCurrency(%q): expected ok=%v, got %v", test.s, test.ok, ok)
+		} else if ok && c.Cmp(test.want) != 0 {
+			t.Errorf("parseCurrency(%q): expected %v, got %v", test.s, test.want, c)
+		}
+	}
+}
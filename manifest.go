@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.sia.tech/siad/types"
+	"lukechampine.com/us/wallet"
+)
+
+// A manifestEntry records a single disbursement made by a transaction.
+type manifestEntry struct {
+	Address types.UnlockHash `json:"address"`
+	Name    string           `json:"name,omitempty"`
+	ValueSC string           `json:"valueSC"`
+	Value   types.Currency   `json:"value"`
+}
+
+// addressNames maps addresses to human-readable names: the standard address of
+// each key in the address book, and each address in the condition set.
+func addressNames() map[types.UnlockHash]string {
+	names := make(map[types.UnlockHash]string)
+	book := loadAddressBook()
+	for _, name := range book.names() {
+		names[wallet.StandardAddress(book[name])] = name
+	}
+	for label, uc := range loadConditionSet() {
+		names[uc.UnlockHash()] = label
+	}
+	return names
+}
+
+// paymentManifest returns an entry for each output of txn, excluding change
+// (outputs returning to an input's address).
+func paymentManifest(txn types.Transaction, names map[types.UnlockHash]string) []manifestEntry {
+	inputAddrs := make(map[types.UnlockHash]bool)
+	for _, in := range txn.SiacoinInputs {
+		inputAddrs[in.UnlockConditions.UnlockHash()] = true
+	}
+	var entries []manifestEntry
+	for _, out := range txn.SiacoinOutputs {
+		if inputAddrs[out.UnlockHash] {
+			continue
+		}
+		entries = append(entries, manifestEntry{
+			Address: out.UnlockHash,
+			Name:    names[out.UnlockHash],
+			ValueSC: strings.Replace(strings.TrimSuffix(formatSC(out.Value), " SC"), ",", "", -1),
+			Value:   out.Value,
+		})
+	}
+	return entries
+}
+
+// encodeManifest encodes entries in the specified format, "json" or "csv".
+func encodeManifest(entries []manifestEntry, format string) ([]byte, error) {
+	switch format {
+	case "json":
+		if entries == nil {
+			entries = []manifestEntry{}
+		}
+		js, _ := json.MarshalIndent(entries, "", "  ")
+		return append(js, '\n'), nil
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"address", "name", "valueSC", "valueHastings"})
+		for _, e := range entries {
+			w.Write([]string{e.Address.String(), e.Name, e.ValueSC, e.Value.String()})
+		}
+		w.Flush()
+		return buf.Bytes(), w.Error()
+	default:
+		return nil, fmt.Errorf("unknown manifest format %q (must be \"json\" or \"csv\")", format)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/types"
+)
+
+// Output IDs may be written as "output references": the ID, encoded with
+// bech32 (BIP-173) under the human-readable prefix "sco". Unlike raw hex, the
+// bech32 checksum catches typos and transpositions.
+const (
+	outputRefPrefix  = "sco"
+	bech32Charset    = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	bech32ChecksumLn = 6
+)
+
+func bech32Polymod(values []byte) uint32 {
+	gen := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		b := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := range gen {
+			if (b>>uint(i))&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	v := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		v = append(v, hrp[i]>>5)
+	}
+	v = append(v, 0)
+	for i := 0; i < len(hrp); i++ {
+		v = append(v, hrp[i]&31)
+	}
+	return v
+}
+
+// convertBits regroups data from groups of fromBits bits to groups of toBits
+// bits. If pad is false, leftover bits must be zero.
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, bool) {
+	var acc, bits uint
+	var out []byte
+	maxv := uint(1)<<toBits - 1
+	for _, v := range data {
+		acc = acc<<fromBits | uint(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+	if pad && bits > 0 {
+		out = append(out, byte(acc<<(toBits-bits)&maxv))
+	} else if !pad && (bits >= fromBits || acc<<(toBits-bits)&maxv != 0) {
+		return nil, false
+	}
+	return out, true
+}
+
+// outputRef returns the checksummed output reference for id.
+func outputRef(id types.SiacoinOutputID) string {
+	data, _ := convertBits(id[:], 8, 5, true)
+	values := append(bech32HRPExpand(outputRefPrefix), data...)
+	mod := bech32Polymod(append(values, make([]byte, bech32ChecksumLn)...)) ^ 1
+	var sb strings.Builder
+	sb.WriteString(outputRefPrefix + "1")
+	for _, d := range data {
+		sb.WriteByte(bech32Charset[d])
+	}
+	for i := 0; i < bech32ChecksumLn; i++ {
+		sb.WriteByte(bech32Charset[(mod>>uint(5*(5-i)))&31])
+	}
+	return sb.String()
+}
+
+// parseOutputID parses s as either a hex-encoded output ID or a checksummed
+// output reference.
+func parseOutputID(s string) (id types.SiacoinOutputID, err error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(strings.ToLower(s), outputRefPrefix+"1") {
+		err = (*crypto.Hash)(&id).LoadString(s)
+		return
+	}
+	if s != strings.ToLower(s) && s != strings.ToUpper(s) {
+		return id, errors.New("output reference must not mix upper and lower case")
+	}
+	s = strings.ToLower(s)
+	var values []byte
+	for _, c := range s[len(outputRefPrefix)+1:] {
+		d := strings.IndexRune(bech32Charset, c)
+		if d < 0 {
+			return id, errors.New("output reference contains invalid character " + string(c))
+		}
+		values = append(values, byte(d))
+	}
+	if len(values) < bech32ChecksumLn || bech32Polymod(append(bech32HRPExpand(outputRefPrefix), values...)) != 1 {
+		return id, errors.New("output reference has an invalid checksum; check it for typos")
+	}
+	data, ok := convertBits(values[:len(values)-bech32ChecksumLn], 5, 8, false)
+	if !ok || len(data) != len(id) {
+		return id, errors.New("output reference has the wrong length")
+	}
+	copy(id[:], data)
+	return id, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"go.sia.tech/siad/types"
+	"lukechampine.com/walrus"
+)
+
+// A probeResult records whether a walrus endpoint responded, and if not, why.
+type probeResult struct {
+	name     string
+	required bool
+	err      error
+}
+
+// probeWalrus checks which of the endpoints used by multisign respond. The
+// broadcast endpoint is exercised with an empty transaction set, which the
+// server rejects without relaying anything; any such rejection still shows that
+// the endpoint exists.
+func probeWalrus(c *walrus.Client) []probeResult {
+	_, consensusErr := c.ConsensusInfo()
+	_, feeErr := c.RecommendedFee()
+	broadcastErr := c.Broadcast([]types.Transaction{})
+	if broadcastErr != nil && connectionProblem(broadcastErr) == "" && !endpointMissing(broadcastErr) {
+		broadcastErr = nil
+	}
+	return []probeResult{
+		{"broadcast", true, broadcastErr},
+		{"chain tip (consensus)", false, consensusErr},
+		{"fee estimation", false, feeErr},
+	}
+}
+
+// endpointMissing reports whether err indicates that the server does not serve
+// the requested endpoint.
+func endpointMissing(err error) bool {
+	s := strings.ToLower(err.Error())
+	return strings.Contains(s, "404") || strings.Contains(s, "not found") || strings.Contains(s, "method not allowed")
+}
+
+// connectionProblem describes err if it prevented the request from reaching the
+// server (or the server from being trusted), and returns "" otherwise.
+func connectionProblem(err error) string {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostname         x509.HostnameError
+		invalid          x509.CertificateInvalidError
+		dnsErr           *net.DNSError
+		opErr            *net.OpError
+		urlErr           *url.Error
+	)
+	switch s := err.Error(); {
+	case errors.As(err, &unknownAuthority):
+		return "the server's certificate is not signed by a trusted CA; pass its CA bundle with -tls-ca"
+	case errors.As(err, &hostname):
+		return "the server's certificate is not valid for this hostname"
+	case errors.As(err, &invalid):
+		return fmt.Sprintf("the server's certificate is invalid (%v)", invalid.Error())
+	case strings.Contains(s, "tls: bad certificate"), strings.Contains(s, "tls: certificate required"):
+		return "the server rejected the client certificate, or requires one; check -tls-cert and -tls-key"
+	case strings.Contains(s, "server gave HTTP response to HTTPS client"):
+		return "the server does not speak TLS; use an http:// URL"
+	case strings.Contains(s, "401"), strings.Contains(strings.ToLower(s), "unauthorized"):
+		return "the server requires authentication"
+	case strings.Contains(s, "403"), strings.Contains(strings.ToLower(s), "forbidden"):
+		return "the server refused access"
+	case errors.As(err, &dnsErr):
+		return fmt.Sprintf("could not resolve %v", dnsErr.Name)
+	case errors.As(err, &opErr):
+		return fmt.Sprintf("could not connect (%v)", opErr.Err)
+	case errors.As(err, &urlErr):
+		return fmt.Sprintf("request failed (%v)", urlErr.Err)
+	}
+	return ""
+}
+
+// printProbeResults prints the outcome of each probe, returning an error if the
+// server cannot be used for broadcasting.
+func printProbeResults(server string, results []probeResult) error {
+	fmt.Println("Server:", server)
+	var fatalErr error
+	for _, r := range results {
+		status := "OK"
+		if r.err != nil {
+			if problem := connectionProblem(r.err); problem != "" {
+				status = "UNREACHABLE: " + problem
+			} else if endpointMissing(r.err) {
+				status = "NOT SUPPORTED"
+			} else {
+				status = "ERROR: " + r.err.Error()
+			}
+			if r.required && fatalErr == nil {
+				fatalErr = fmt.Errorf("%v: %v", r.name, strings.ToLower(status))
+			}
+		}
+		fmt.Printf("  %-22v %v\n", r.name+":", status)
+	}
+	return withKind(errBroadcastFailed, fatalErr)
+}
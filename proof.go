@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/types"
+)
+
+// A controlProof demonstrates control of a multisig address: enough of the
+// address's keys to meet its threshold each sign a challenge chosen by the
+// verifier (e.g. an exchange or auditor).
+type controlProof struct {
+	Version          int                    `json:"version"`
+	Address          types.UnlockHash       `json:"address"`
+	UnlockConditions types.UnlockConditions `json:"unlockConditions"`
+	Challenge        string                 `json:"challenge"`
+	Signatures       []proofSignature       `json:"signatures"`
+}
+
+type proofSignature struct {
+	PublicKeyIndex uint64 `json:"publicKeyIndex"`
+	Signature      []byte `json:"signature"`
+}
+
+// MarshalJSON implements json.Marshaler, encoding the UnlockConditions in the
+// same form as the addr command.
+func (p controlProof) MarshalJSON() ([]byte, error) {
+	type proof controlProof
+	return json.Marshal(struct {
+		proof
+		UnlockConditions jsonUnlockConditions `json:"unlockConditions"`
+	}{proof(p), jsonUnlockConditions(p.UnlockConditions)})
+}
+
+// sigHash returns the hash signed by each key. The challenge is bound to the
+// address, and prefixed so that the signature cannot be mistaken for a
+// transaction signature.
+func (p controlProof) sigHash() crypto.Hash {
+	return crypto.HashAll("multisign proof of control", p.Address, p.Challenge)
+}
+
+// sign adds a signature from each key of the address that seed controls and
+// that has not already signed, returning the number of signatures added.
+func (p *controlProof) sign(seed signingSeed, scan keyScan) int {
+	signed := make(map[uint64]bool)
+	for _, sig := range p.Signatures {
+		signed[sig.PublicKeyIndex] = true
+	}
+	indices := scan.find(seed, p.UnlockConditions.PublicKeys)
+	h := p.sigHash()
+	var added int
+	for i, spk := range p.UnlockConditions.PublicKeys {
+		index, ok := indices[string(spk.Key)]
+		if !ok || signed[uint64(i)] {
+			continue
+		}
+		p.Signatures = append(p.Signatures, proofSignature{
+			PublicKeyIndex: uint64(i),
+			Signature:      ed25519.Sign(seed.SecretKey(index), h[:]),
+		})
+		signed[uint64(i)] = true
+		added++
+	}
+	return added
+}
+
+// validSignatures returns the number of distinct keys that validly signed p,
+// along with a description of each problem found.
+func (p controlProof) validSignatures() (valid int, problems []string) {
+	if p.UnlockConditions.UnlockHash() != p.Address {
+		problems = append(problems, fmt.Sprintf("UnlockConditions hash to %v, not the claimed address %v", p.UnlockConditions.UnlockHash(), p.Address))
+		return 0, problems
+	}
+	h := p.sigHash()
+	seen := make(map[uint64]bool)
+	for i, sig := range p.Signatures {
+		switch {
+		case sig.PublicKeyIndex >= uint64(len(p.UnlockConditions.PublicKeys)):
+			problems = append(problems, fmt.Sprintf("signature %v: public key index %v is out of bounds", i, sig.PublicKeyIndex))
+		case seen[sig.PublicKeyIndex]:
+			problems = append(problems, fmt.Sprintf("signature %v: duplicate signature from key %v", i, sig.PublicKeyIndex))
+		default:
+			spk := p.UnlockConditions.PublicKeys[sig.PublicKeyIndex]
+			if spk.Algorithm != types.SignatureEd25519 || len(spk.Key) != ed25519.PublicKeySize || !ed25519.Verify(spk.Key, h[:], sig.Signature) {
+				problems = append(problems, fmt.Sprintf("signature %v: invalid signature from key %v", i, sig.PublicKeyIndex))
+			} else {
+				seen[sig.PublicKeyIndex] = true
+				valid++
+			}
+		}
+	}
+	return valid, problems
+}
+
+func readControlProof(filename string) (controlProof, error) {
+	js, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return controlProof{}, err
+	}
+	var p controlProof
+	if err := json.Unmarshal(js, &p); err != nil {
+		return controlProof{}, err
+	} else if p.Version != 1 {
+		return controlProof{}, fmt.Errorf("unsupported proof version %v", p.Version)
+	}
+	return p, nil
+}
+
+func writeControlProof(filename string, p controlProof) {
+	js, _ := json.MarshalIndent(p, "", "  ")
+	check(ioutil.WriteFile(filename, append(js, '\n'), 0666), "Could not write proof")
+}
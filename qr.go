@@ -0,0 +1,426 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+)
+
+// A qrCode is a QR code symbol, encoded in byte mode with error correction
+// level M. Only versions 1 through 6 (up to 106 bytes) are supported, which
+// suffices for addresses.
+type qrCode struct {
+	size     int
+	modules  [][]bool // dark modules, indexed [y][x]
+	function [][]bool // modules belonging to function patterns
+}
+
+// qrVersions lists, for each supported version at error correction level M,
+// the number of error correction codewords per block, the number of blocks,
+// and the position of the second alignment pattern (if any).
+var qrVersions = []struct {
+	eccPerBlock int
+	blocks      int
+	dataPerBlk  int
+	alignment   int
+}{
+	{10, 1, 16, 0},
+	{16, 1, 28, 18},
+	{26, 1, 44, 22},
+	{18, 2, 32, 26},
+	{24, 2, 43, 30},
+	{16, 4, 27, 34},
+}
+
+// newQRCode encodes data as a QR code, using the smallest supported version
+// that fits.
+func newQRCode(data []byte) (*qrCode, error) {
+	for i, v := range qrVersions {
+		capacity := v.blocks * v.dataPerBlk
+		if 4+8+8*len(data) > capacity*8 {
+			continue
+		}
+		// encode as a single byte-mode segment, then pad to capacity
+		var bits []bool
+		appendBits := func(val, n int) {
+			for j := n - 1; j >= 0; j-- {
+				bits = append(bits, (val>>uint(j))&1 == 1)
+			}
+		}
+		appendBits(0x4, 4)
+		appendBits(len(data), 8)
+		for _, b := range data {
+			appendBits(int(b), 8)
+		}
+		for j := 0; j < 4 && len(bits) < capacity*8; j++ {
+			bits = append(bits, false)
+		}
+		for len(bits)%8 != 0 {
+			bits = append(bits, false)
+		}
+		for pad := 0xEC; len(bits) < capacity*8; pad ^= 0xEC ^ 0x11 {
+			appendBits(pad, 8)
+		}
+		codewords := make([]byte, capacity)
+		for j, b := range bits {
+			if b {
+				codewords[j/8] |= 1 << uint(7-j%8)
+			}
+		}
+
+		q := &qrCode{size: 17 + 4*(i+1)}
+		q.modules = make([][]bool, q.size)
+		q.function = make([][]bool, q.size)
+		for y := range q.modules {
+			q.modules[y] = make([]bool, q.size)
+			q.function[y] = make([]bool, q.size)
+		}
+		q.drawFunctionPatterns(v.alignment)
+		q.drawCodewords(interleaveBlocks(codewords, v.blocks, v.eccPerBlock))
+		q.applyBestMask()
+		return q, nil
+	}
+	return nil, errors.New("data is too long to encode as a QR code")
+}
+
+// interleaveBlocks splits data into equal blocks, computes the error correction
+// codewords of each, and interleaves the results.
+func interleaveBlocks(data []byte, blocks, eccLen int) []byte {
+	blockLen := len(data) / blocks
+	divisor := rsDivisor(eccLen)
+	var out []byte
+	for i := 0; i < blockLen; i++ {
+		for b := 0; b < blocks; b++ {
+			out = append(out, data[b*blockLen+i])
+		}
+	}
+	eccs := make([][]byte, blocks)
+	for b := range eccs {
+		eccs[b] = rsRemainder(data[b*blockLen:(b+1)*blockLen], divisor)
+	}
+	for i := 0; i < eccLen; i++ {
+		for b := range eccs {
+			out = append(out, eccs[b][i])
+		}
+	}
+	return out
+}
+
+// gfMul multiplies x and y in GF(2^8), modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMul(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+// rsDivisor returns the Reed-Solomon generator polynomial of the specified
+// degree, omitting its leading coefficient.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder returns the Reed-Solomon error correction codewords of data.
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i := range result {
+			result[i] ^= gfMul(divisor[i], factor)
+		}
+	}
+	return result
+}
+
+func (q *qrCode) setFunction(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.function[y][x] = true
+}
+
+func (q *qrCode) drawFunctionPatterns(alignment int) {
+	// timing patterns
+	for i := 0; i < q.size; i++ {
+		q.setFunction(6, i, i%2 == 0)
+		q.setFunction(i, 6, i%2 == 0)
+	}
+	// finder patterns, with separators
+	for _, c := range [][2]int{{3, 3}, {q.size - 4, 3}, {3, q.size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if 0 <= x && x < q.size && 0 <= y && y < q.size {
+					dist := absInt(dx)
+					if absInt(dy) > dist {
+						dist = absInt(dy)
+					}
+					q.setFunction(x, y, dist != 2 && dist != 4)
+				}
+			}
+		}
+	}
+	// versions 2-6 have a single alignment pattern, in the bottom right
+	if alignment != 0 {
+		for dy := -2; dy <= 2; dy++ {
+			for dx := -2; dx <= 2; dx++ {
+				q.setFunction(alignment+dx, alignment+dy, absInt(dx) == 2 || absInt(dy) == 2 || (dx == 0 && dy == 0))
+			}
+		}
+	}
+	// reserve the format bits; they are drawn once the mask is chosen
+	q.drawFormatBits(0)
+}
+
+// drawFormatBits draws the format information for error correction level M and
+// the specified mask.
+func (q *qrCode) drawFormatBits(mask int) {
+	data := mask // level M is encoded as 0
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>uint(i))&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		q.setFunction(8, i, bit(i))
+	}
+	q.setFunction(8, 7, bit(6))
+	q.setFunction(8, 8, bit(7))
+	q.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.setFunction(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		q.setFunction(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.setFunction(8, q.size-15+i, bit(i))
+	}
+	q.setFunction(8, q.size-8, true) // always dark
+}
+
+// drawCodewords places data in the zigzag pattern used by QR codes.
+func (q *qrCode) drawCodewords(data []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = q.size - 1 - vert
+				}
+				if !q.function[y][x] && i < len(data)*8 {
+					q.modules[y][x] = (data[i/8]>>uint(7-i%8))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules selected by the specified mask pattern.
+// Applying the same mask twice undoes it.
+func (q *qrCode) applyMask(mask int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !q.function[y][x] {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+// applyBestMask applies the mask pattern that minimizes the penalty score.
+func (q *qrCode) applyBestMask() {
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormatBits(mask)
+		if p := q.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		q.applyMask(mask)
+	}
+	q.applyMask(best)
+	q.drawFormatBits(best)
+}
+
+// penalty scores the appearance of the symbol; lower scores are easier to scan.
+func (q *qrCode) penalty() int {
+	var p int
+	at := func(x, y int, vertical bool) bool {
+		if vertical {
+			return q.modules[x][y]
+		}
+		return q.modules[y][x]
+	}
+	finder := []bool{true, false, true, true, true, false, true}
+	for _, vertical := range []bool{false, true} {
+		for y := 0; y < q.size; y++ {
+			// runs of five or more same-colored modules
+			run := 1
+			for x := 1; x < q.size; x++ {
+				if at(x, y, vertical) == at(x-1, y, vertical) {
+					run++
+					if run == 5 {
+						p += 3
+					} else if run > 5 {
+						p++
+					}
+				} else {
+					run = 1
+				}
+			}
+			// patterns resembling a finder, with four light modules on
+			// either side (modules outside the symbol count as light)
+			for x := -4; x+len(finder)+4 <= q.size+4; x++ {
+				match := true
+				for k, dark := range finder {
+					if xx := x + k; xx < 0 || xx >= q.size || at(xx, y, vertical) != dark {
+						match = false
+						break
+					}
+				}
+				if !match {
+					continue
+				}
+				lightRun := func(from, to int) bool {
+					for xx := from; xx < to; xx++ {
+						if xx >= 0 && xx < q.size && at(xx, y, vertical) {
+							return false
+						}
+					}
+					return true
+				}
+				if lightRun(x-4, x) || lightRun(x+7, x+11) {
+					p += 40
+				}
+			}
+		}
+	}
+	// 2x2 blocks of the same color
+	var dark int
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if q.modules[y][x] {
+				dark++
+			}
+			if x+1 < q.size && y+1 < q.size {
+				c := q.modules[y][x]
+				if c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+					p += 3
+				}
+			}
+		}
+	}
+	// imbalance between dark and light modules
+	total := q.size * q.size
+	k := (absInt(dark*20-total*10)+total-1)/total - 1
+	if k > 0 {
+		p += k * 10
+	}
+	return p
+}
+
+func absInt(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// qrQuietZone is the width, in modules, of the light border around a symbol.
+const qrQuietZone = 4
+
+func (q *qrCode) darkAt(x, y int) bool {
+	x, y = x-qrQuietZone, y-qrQuietZone
+	return 0 <= x && x < q.size && 0 <= y && y < q.size && q.modules[y][x]
+}
+
+// writeTerminal renders the symbol to w using ANSI background colors, so that
+// it scans regardless of the terminal's color scheme.
+func (q *qrCode) writeTerminal(w io.Writer) {
+	const dark, light, reset = "\x1b[40m  ", "\x1b[47m  ", "\x1b[0m"
+	n := q.size + 2*qrQuietZone
+	var sb strings.Builder
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if q.darkAt(x, y) {
+				sb.WriteString(dark)
+			} else {
+				sb.WriteString(light)
+			}
+		}
+		sb.WriteString(reset + "\n")
+	}
+	fmt.Fprint(w, sb.String())
+}
+
+// writePNG writes the symbol to filename as a PNG, with each module drawn as a
+// square of the specified number of pixels.
+func (q *qrCode) writePNG(filename string, scale int) error {
+	n := (q.size + 2*qrQuietZone) * scale
+	img := image.NewGray(image.Rect(0, 0, n, n))
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			c := color.Gray{Y: 255}
+			if q.darkAt(x/scale, y/scale) {
+				c.Y = 0
+			}
+			img.SetGray(x, y, c)
+		}
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// qrVectors are module matrices ("#" for dark) produced by Kazuhiko Arase's
+// reference QR code encoder at level M, with the mask pattern forced to the one
+// that newQRCode selects.
+var qrVectors = []struct {
+	data    string
+	modules []string
+}{
+	{
+		// version 1, mask 6
+		"multisign",
+		[]string{
+			"#######.#..#..#######",
+			"#.....#.#.#...#.....#",
+			"#.###.#.#.#.#.#.###.#",
+			"#.###.#..####.#.###.#",
+			"#.###.#.#####.#.###.#",
+			"#.....#..#....#.....#",
+			"#######.#.#.#.#######",
+			".........##..........",
+			"#..######.##.#..#.###",
+			"#..##..###.####..###.",
+			"#.#...##...###....###",
+			"###....##...##.##.##.",
+			"...####.#..###.#.#...",
+			"........#...#...##.#.",
+			"#######.#..#.##.#.#..",
+			"#.....#.#.#...##.####",
+			"#.###.#.#.#...##.....",
+			"#.###.#.###.####..#..",
+			"#.###.#..#.###..#####",
+			"#.....#....##..#.####",
+			"#######.###.##.#.....",
+		},
+	},
+	{
+		// version 3, mask 4
+		"hello, world! this is a version 3 code",
+		[]string{
+			"#######.#..#.####.#...#######",
+			"#.....#..#..##..#.###.#.....#",
+			"#.###.#..##......##...#.###.#",
+			"#.###.#.#.##.##.#.....#.###.#",
+			"#.###.#.#.#..####.#.#.#.###.#",
+			"#.....#.#....#..#.##..#.....#",
+			"#######.#.#.#.#.#.#.#.#######",
+			"........###.#.#.##.#.........",
+			"#...#.####...##..#.#.#####..#",
+			"##..#..##.##..###.....#.#...#",
+			".##.#.##..#.#####.#.####....#",
+			"####.........#.###....#..#.##",
+			".#.#.##.##..##...#..#....#.##",
+			"####.#.#....####..#...###...#",
+			"..###.####.....#.##..###.##.#",
+			"#.#.#..#...#..#.###..##.#...#",
+			".#...##....####.##.###...#.#.",
+			"##.#...##.###..#....#####...#",
+			"..##..#.##.######...#.###...#",
+			"...#.#.#.#..##.###.#.###...##",
+			"####..#.###.##...#.#######..#",
+			"........##..####....#...###.#",
+			"#######.#...#..##..##.#.#...#",
+			"#.....#..##.#.#..####...##.#.",
+			"#.###.#.####.###.#.######..#.",
+			"#.###.#..###...####.##.#..#.#",
+			"#.###.#...#.##.#.#..##...####",
+			"#.....#..#.#.##..##.##..##.##",
+			"#######.####..##.#..#..#.#.#.",
+		},
+	},
+	{
+		// version 5, mask 2
+		"0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20441edc56cebc",
+		[]string{
+			"#######..#..##.#..#..#.#.##...#######",
+			"#.....#..#.###.#..#.##.....#..#.....#",
+			"#.###.#.###..##.#..#.#..#..##.#.###.#",
+			"#.###.#.#.......###.#.#..#.##.#.###.#",
+			"#.###.#.#...##..##.#.#.##.#...#.###.#",
+			"#.....#.#.#.###.##..###....#..#.....#",
+			"#######.#.#.#.#.#.#.#.#.#.#.#.#######",
+			"........##.#....###..####.##.........",
+			"#.#####......#.##.....#..###..#####..",
+			"###..#..##.#..##..####.##...#..#.#.#.",
+			"#...###..##..####.#..##.#..##.##....#",
+			".##..#........##...#.#.....#..#..#.##",
+			".#...##..####.#####.#...##.##.###.#.#",
+			"..#....##.###.#....#.###..#..#...#.#.",
+			"#..#####.....####.#..##.#.###.#..#..#",
+			"#..........###.#.....#..#.#.####.#...",
+			"##.#.##.##.#####..#...#..#.##.###.###",
+			"##..##.###..#.###..###.####..#.....#.",
+			"....###..###..#..##.##..#..#####..#.#",
+			".#.###.......#..#.#.##....#..##.##..#",
+			"#.###.####.#..#.###.#.#..#.##.###.#.#",
+			"...#.#.#.#.###...#.#.#.##.#..#...#.#.",
+			".#.##.##.#.###.#.#..#...#..#..#..##.#",
+			"#...##.#####..#####..####.##.##..#.##",
+			"#.######...#...##........###.####.#.#",
+			"####...####..#.#..#######...#....#.#.",
+			"#..#..####.#.#.##.#.###.#..##.##....#",
+			"#.#.#..###.###.#...#.##.#..#..#..#..#",
+			"#..#.#####.##..####.#...#########.##.",
+			"........#..##.#....#.###....#...##.#.",
+			"#######....######.#..##.###.#.#.##..#",
+			"#.....#.##.#...#...####.#..##...##.##",
+			"#.###.#.##.##.##..#......#.######.###",
+			"#.###.#.##...#.##..######.###.#####.#",
+			"#.###.#.##.####..##..#..#....#...#.##",
+			"#.....#..##.#...#..###.##.#.#.####..#",
+			"#######.#.#.###.###...#..#.#......###",
+		},
+	},
+	{
+		// version 6, mask 2
+		"ed25519:c3064a3568fc5a38edcd37231f5e1fc016942e74d6ad63570e566c1e6c02c224 ed25519:64e1",
+		[]string{
+			"#######....##..#.#.#.#.####....#..#######",
+			"#.....#...#.#.####..##..####..#.#.#.....#",
+			"#.###.#.##.##.#####.###..#.#...#..#.###.#",
+			"#.###.#.###.##.#.....#..#.###.#...#.###.#",
+			"#.###.#.#.#...#..#######.......##.#.###.#",
+			"#.....#.#.#...###.#...#.#..#.##.#.#.....#",
+			"#######.#.#.#.#.#.#.#.#.#.#.#.#.#.#######",
+			"........##.#...#..####.##.##....#........",
+			"#.#####...#.##..##..#.#.##.#.##...#####..",
+			".##.#..###.#.#.#..##.#.#..#.#..##.#.##..#",
+			"....###..##..#....#.###.#..#.....#.###.#.",
+			".###.#..####...##..#.##.#.###..#...###.##",
+			"#.##..#.#.....#..#..#..###.#####.#.#..###",
+			"..#.#..#.#.##.....#..##...##....##..##..#",
+			"#.##..####.#####....##.###.##.##.......#.",
+			"#..##..#.###.#..#....#.#...#...##...#...#",
+			"##.#.##...###.#..##.#.##.#####.#.#.#.###.",
+			"#.#.##.####.###.#..#...##....####.#.##.##",
+			".#.#..#.###.#..##.#...#.#..###...#.###...",
+			"....#..#..#...#.#..#...##.#..####.###...#",
+			"###.#.#..###....#.#.###....##...##.#.###.",
+			"#.#.##.####...#....#.#.##.#...##..#.##.##",
+			"##..#.##.###...##.......##.####....##.#..",
+			"...#...##..#...##...###...#....##..##....",
+			"####.##.#..###.###..#....#.####.##...##..",
+			".###...###.#.#.#..##.####.#....#..####..#",
+			".##########.#.##....#.#.####..#.#.#.#..#.",
+			"#.##.#.#..#.#..#..#..###....#....##.#....",
+			"#######.#.#.#..####......#.#.#.##.##.####",
+			"##........#.#####...###.#.###.#..#..#...#",
+			"#....######...###.##.##..#......##.#.###.",
+			"#..#...#.###.#.....####.#.#.#......##..##",
+			"#..####.#..##.#.#####.#..#...##.#######..",
+			"........##..###..#.#.#.####.#..##...##.##",
+			"#######..#...#..##...#..####..###.#.###..",
+			"#.....#.#.#.#.##.##.######..#...#...#..#.",
+			"#.###.#.#.##...##....#....##..#.#######..",
+			"#.###.#.#..#.#...########...#...##.#.#...",
+			"#.###.#.#...##..###..##...##..##..#.#....",
+			"#.....#..#..####....###.#.#.#.#.##...#.#.",
+			"#######.##.####.......##.#.#####....#.#..",
+		},
+	},
+}
+
+func TestQRCodeVectors(t *testing.T) {
+	for _, v := range qrVectors {
+		q, err := newQRCode([]byte(v.data))
+		if err != nil {
+			t.Fatal(err)
+		} else if q.size != len(v.modules) {
+			t.Errorf("%q: expected size %v, got %v", v.data, len(v.modules), q.size)
+			continue
+		}
+		for y, row := range v.modules {
+			var got strings.Builder
+			for x := 0; x < q.size; x++ {
+				if q.modules[y][x] {
+					got.WriteByte('#')
+				} else {
+					got.WriteByte('.')
+				}
+			}
+			if got.String() != row {
+				t.Errorf("%q: row %v mismatch:\ngot:  %v\nwant: %v", v.data, y, got.String(), row)
+			}
+		}
+	}
+}
+
+func TestQRCodeTooLong(t *testing.T) {
+	if _, err := newQRCode(make([]byte, 107)); err == nil {
+		t.Fatal("expected data beyond version 6 capacity to be rejected")
+	}
+}
+
+func TestRSRemainder(t *testing.T) {
+	// the version 1-M example from ISO/IEC 18004, encoding "01234567"
+	data := []byte{0x10, 0x20, 0x0C, 0x56, 0x61, 0x80, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11}
+	want := []byte{0xA5, 0x24, 0xD4, 0xC1, 0xED, 0x36, 0xC7, 0x87, 0x2C, 0x55}
+	if got := rsRemainder(data, rsDivisor(len(want))); !bytes.Equal(got, want) {
+		t.Fatalf("expected %X, got %X", want, got)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+
+	"go.sia.tech/siad/types"
+)
+
+// errSearchExhausted is returned by recoverConditions when the search bound is
+// reached before every combination has been tried.
+var errSearchExhausted = errors.New("search bound reached")
+
+// recoverConditions searches for UnlockConditions with the specified timelock
+// that hash to addr, using an ordered selection of the candidate keys and any
+// threshold. At most maxAttempts combinations are tried. If no combination
+// matches, it returns errSearchExhausted if the bound was reached, or nil and
+// ok == false if every combination was tried.
+func recoverConditions(addr types.UnlockHash, timelock types.BlockHeight, candidates []types.SiaPublicKey, maxAttempts uint64) (uc types.UnlockConditions, attempts uint64, ok bool, err error) {
+	used := make([]bool, len(candidates))
+	keys := make([]types.SiaPublicKey, 0, len(candidates))
+	// search tries every ordered selection of n keys, with every threshold
+	var search func(n int) bool
+	search = func(n int) bool {
+		if len(keys) == n {
+			for m := 1; m <= n; m++ {
+				if attempts >= maxAttempts {
+					err = errSearchExhausted
+					return true
+				}
+				attempts++
+				uc = types.UnlockConditions{
+					Timelock:           timelock,
+					PublicKeys:         keys,
+					SignaturesRequired: uint64(m),
+				}
+				if uc.UnlockHash() == addr {
+					uc.PublicKeys = append([]types.SiaPublicKey(nil), keys...)
+					ok = true
+					return true
+				}
+			}
+			return false
+		}
+		for i := range candidates {
+			if used[i] {
+				continue
+			}
+			used[i] = true
+			keys = append(keys, candidates[i])
+			done := search(n)
+			keys = keys[:len(keys)-1]
+			used[i] = false
+			if done {
+				return true
+			}
+		}
+		return false
+	}
+	// try smaller key sets first, since they are cheaper to exhaust
+	for n := 1; n <= len(candidates); n++ {
+		if search(n) {
+			break
+		}
+	}
+	if !ok {
+		uc = types.UnlockConditions{}
+	}
+	return
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"gitlab.com/NebulousLabs/bolt"
+	"go.sia.tech/multisign/foundation"
+	"go.sia.tech/siad/types"
+)
+
+// An updateProposal is a Foundation unlock hash update, proposed for review
+// independently of the transaction that will eventually carry it.
+type updateProposal struct {
+	Summary          string                           `json:"summary"`
+	FoundationUpdate types.FoundationUnlockHashUpdate `json:"foundationUpdate"`
+	ArbitraryData    []byte                           `json:"arbitraryData"`
+}
+
+// confirmUpdate decodes arb exactly as checkTxn would, and has the user confirm
+// that it matches their intent. It returns false if arb does not decode to
+// want, or if the user does not confirm.
+func confirmUpdate(arb []byte, want types.FoundationUnlockHashUpdate) bool {
+	decoded, err := foundation.DecodeUpdate(arb)
+	if err != nil || decoded != want {
+		fmt.Println("Encoded update does not match the provided addresses.")
+		return false
+	}
+	fmt.Println("Foundation Unlock Hash Update:")
+	fmt.Println("New Primary: ", decoded.NewPrimary)
+	fmt.Println("New Failsafe:", decoded.NewFailsafe)
+	confirm := strings.ToLower(ask("Is this correct? [y/n]"))
+	return confirm == "y" || confirm == "yes"
+}
+
+// proposeUpdate writes a proposal to update the Foundation addresses to
+// filename.
+func proposeUpdate(primary, failsafe types.UnlockHash, filename string) {
+	p := updateProposal{
+		Summary:          fmt.Sprintf("Change the Foundation primary address to %v and the failsafe address to %v", primary, failsafe),
+		FoundationUpdate: types.FoundationUnlockHashUpdate{NewPrimary: primary, NewFailsafe: failsafe},
+		ArbitraryData:    foundation.UpdateArbitraryData(primary, failsafe),
+	}
+	if !confirmUpdate(p.ArbitraryData, p.FoundationUpdate) {
+		log.Fatal("Proposal aborted.")
+	}
+	js, _ := json.MarshalIndent(p, "", "  ")
+	err := ioutil.WriteFile(filename, append(js, '\n'), 0666)
+	check(err, "Could not write proposal")
+	fmt.Println("Wrote update proposal to", filename)
+}
+
+// attachUpdate adds the update in the proposal file to the transaction file, at
+// position index of its arbitrary data (or last, if index is negative). If
+// replace is set, an existing update is replaced; otherwise, its presence is an
+// error.
+func attachUpdate(proposalFile, txnFilename string, index int, replace bool) {
+	js, err := ioutil.ReadFile(proposalFile)
+	check(err, "Could not read proposal")
+	var p updateProposal
+	check(json.Unmarshal(js, &p), "Could not parse proposal")
+	if !bytes.Equal(p.ArbitraryData, foundation.UpdateArbitraryData(p.FoundationUpdate.NewPrimary, p.FoundationUpdate.NewFailsafe)) {
+		log.Fatal("Proposal's arbitrary data does not match its stated update; refusing to attach it.")
+	}
+
+	f := readTxnFile(txnFilename)
+	for _, arb := range f.txn.ArbitraryData {
+		if foundation.IsUpdate(arb) && !replace {
+			log.Fatal("Transaction already contains a Foundation update; pass -replace to replace it.")
+		}
+	}
+	if len(f.txn.TransactionSignatures) != 0 {
+		log.Fatal("Transaction has already been signed; attaching an update would invalidate its signatures.")
+	}
+	fmt.Println("Proposal:", p.Summary)
+	if !confirmUpdate(p.ArbitraryData, p.FoundationUpdate) {
+		log.Fatal("Update not attached.")
+	}
+	err = foundation.PlaceUpdate(&f.txn, p.ArbitraryData, index)
+	check(err, "Could not attach update")
+	writeTxnFile(txnFilename, f)
+	fmt.Println("Attached update to", txnFilename)
+}
+
+// previewUpdate prints the Foundation addresses before and after the update in
+// the transaction file would be applied to the consensus set at consensusPath.
+func previewUpdate(txnFilename, consensusPath string) {
+	f := readTxnFile(txnFilename)
+	db := openConsensusDB(consensusPath)
+	defer db.Close()
+	var primary, failsafe types.UnlockHash
+	var height types.BlockHeight
+	var ok bool
+	db.View(func(tx *bolt.Tx) error {
+		primary, failsafe, ok = foundation.UnlockHashes(tx)
+		height = foundation.CurrentHeight(tx)
+		return nil
+	})
+	if !ok {
+		log.Fatal("Consensus set does not contain the Foundation addresses; is it synced past the Foundation hardfork?")
+	}
+
+	// updates are applied in order, so the last one wins
+	newPrimary, newFailsafe := primary, failsafe
+	var updates int
+	for _, arb := range f.txn.ArbitraryData {
+		if !foundation.IsUpdate(arb) {
+			continue
+		}
+		update, err := foundation.DecodeUpdate(arb)
+		if err != nil {
+			fmt.Println("WARNING: transaction contains an invalid Foundation update:", err)
+			continue
+		}
+		newPrimary, newFailsafe = update.NewPrimary, update.NewFailsafe
+		updates++
+	}
+	switch {
+	case updates == 0:
+		fmt.Println("WARNING: transaction contains no Foundation update; the addresses will not change.")
+	case updates > 1:
+		fmt.Printf("WARNING: transaction contains %v Foundation updates; only the last one will take effect.\n", updates)
+	}
+
+	// the update is only valid if the transaction spends from a current
+	// Foundation address
+	authorized := false
+	for _, in := range f.txn.SiacoinInputs {
+		if uh := in.UnlockConditions.UnlockHash(); uh == primary || uh == failsafe {
+			authorized = true
+		}
+	}
+	if updates > 0 && !authorized {
+		fmt.Println("WARNING: transaction spends no input from the current primary or failsafe address; the update will be rejected.")
+	}
+
+	describe := func(before, after types.UnlockHash) string {
+		if before == after {
+			return fmt.Sprintf("%v (unchanged)", before)
+		}
+		return fmt.Sprintf("%v\n           -> %v", before, after)
+	}
+	fmt.Println()
+	fmt.Printf("Foundation addresses at height %v, before and after this transaction:\n", height)
+	fmt.Println("  Primary: ", describe(primary, newPrimary))
+	fmt.Println("  Failsafe:", describe(failsafe, newFailsafe))
+}
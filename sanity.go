@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+
+	"go.sia.tech/multisign/foundation"
+	"go.sia.tech/siad/types"
+)
+
+// maxFeePercent is the largest miner fee, as a percentage of the value a
+// transaction spends, that economicWarnings accepts.
+var maxFeePercent = 1.0
+
+// addSanityFlags adds flags configuring the thresholds of economicWarnings to
+// cmd.
+func addSanityFlags(cmd *flag.FlagSet) {
+	addMaxFeeFlag(cmd)
+	cmd.Float64Var(&maxFeePercent, "max-fee-percent", maxFeePercent, "largest miner fee to accept, as a percentage of the value spent")
+}
+
+// economicWarnings describes each economically suspicious property of txn: a
+// miner fee above maxMinerFee or maxFeePercent of the value spent, outputs to
+// addresses not in names, and Foundation updates to addresses not in names.
+// Outputs returning to an input's address (change) are always recognized.
+// These do not make the transaction invalid, but may indicate that a signer is
+// being asked to authorize something other than what they were told.
+func economicWarnings(txn types.Transaction, names map[types.UnlockHash]string) []string {
+	var warnings []string
+	var fee, total types.Currency
+	for _, mf := range txn.MinerFees {
+		fee = fee.Add(mf)
+	}
+	for _, out := range txn.SiacoinOutputs {
+		total = total.Add(out.Value)
+	}
+	total = total.Add(fee)
+	if fee.Cmp(maxMinerFee) > 0 {
+		warnings = append(warnings, fmt.Sprintf("miner fee of %v exceeds the maximum of %v", formatSC(fee), formatSC(maxMinerFee)))
+	}
+	if !total.IsZero() {
+		pct := new(big.Rat).SetFrac(fee.Mul64(100).Big(), total.Big())
+		if limit := new(big.Rat).SetFloat64(maxFeePercent); limit != nil && pct.Cmp(limit) > 0 {
+			f, _ := pct.Float64()
+			warnings = append(warnings, fmt.Sprintf("miner fee of %v is %.2f%% of the value spent, above the maximum of %v%%", formatSC(fee), f, maxFeePercent))
+		}
+	}
+
+	inputAddrs := make(map[types.UnlockHash]bool)
+	for _, in := range txn.SiacoinInputs {
+		inputAddrs[in.UnlockConditions.UnlockHash()] = true
+	}
+	for i, out := range txn.SiacoinOutputs {
+		if _, ok := names[out.UnlockHash]; !ok && !inputAddrs[out.UnlockHash] {
+			warnings = append(warnings, fmt.Sprintf("output %v sends %v to unrecognized address %v", i, formatSC(out.Value), out.UnlockHash))
+		}
+	}
+	for _, arb := range txn.ArbitraryData {
+		update, err := foundation.DecodeUpdate(arb)
+		if err != nil {
+			continue
+		}
+		if _, ok := names[update.NewPrimary]; !ok {
+			warnings = append(warnings, fmt.Sprintf("Foundation update sets the primary address to unrecognized address %v", update.NewPrimary))
+		}
+		if _, ok := names[update.NewFailsafe]; !ok {
+			warnings = append(warnings, fmt.Sprintf("Foundation update sets the failsafe address to unrecognized address %v", update.NewFailsafe))
+		}
+	}
+	return warnings
+}
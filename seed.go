@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	mnemonics "gitlab.com/NebulousLabs/entropy-mnemonics"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+	"golang.org/x/term"
+	"lukechampine.com/us/wallet"
+)
+
+// A signingSeed derives keys from a seed.
+type signingSeed interface {
+	PublicKey(index uint64) types.SiaPublicKey
+	SecretKey(index uint64) ed25519.PrivateKey
+}
+
+// A siadSeed derives keys the same way as the siad wallet.
+type siadSeed modules.Seed
+
+func (s siadSeed) keyPair(index uint64) (crypto.SecretKey, crypto.PublicKey) {
+	return crypto.GenerateKeyPairDeterministic(crypto.HashAll(modules.Seed(s), index))
+}
+
+func (s siadSeed) PublicKey(index uint64) types.SiaPublicKey {
+	_, pk := s.keyPair(index)
+	return types.Ed25519PublicKey(pk)
+}
+
+func (s siadSeed) SecretKey(index uint64) ed25519.PrivateKey {
+	sk, _ := s.keyPair(index)
+	return ed25519.PrivateKey(sk[:])
+}
+
+// addSchemeFlag adds a flag selecting the key derivation scheme to cmd.
+func addSchemeFlag(cmd *flag.FlagSet) *string {
+	return cmd.String("scheme", "us", `key derivation scheme: "us" (12-word seeds) or "siad" (28/29-word siad wallet seeds)`)
+}
+
+// parseSeed parses phrase as a seed of the specified scheme.
+func parseSeed(phrase, scheme string) (signingSeed, error) {
+	switch scheme {
+	case "us":
+		seed, err := wallet.SeedFromPhrase(phrase)
+		if err != nil {
+			return nil, withKind(errInvalidSeed, fmt.Errorf("Invalid seed: %w", err))
+		}
+		return seed, nil
+	case "siad":
+		seed, err := modules.StringToSeed(phrase, mnemonics.English)
+		if err != nil {
+			return nil, withKind(errInvalidSeed, fmt.Errorf("Invalid siad seed: %w", err))
+		}
+		return siadSeed(seed), nil
+	default:
+		return nil, fmt.Errorf("Unknown key derivation scheme %q (must be \"us\" or \"siad\")", scheme)
+	}
+}
+
+func readSeedPhrase(scheme string) string {
+	if scheme != "us" && scheme != "siad" {
+		log.Fatalf("Unknown key derivation scheme %q (must be \"us\" or \"siad\")", scheme)
+	}
+	return readSecret("Seed", "seed")
+}
+
+// readSecret prompts for a secret, such as a seed, reading it without echo. If
+// hidden input is unavailable, e.g. because the secret is piped in, it falls
+// back to reading a plain line.
+func readSecret(prompt, noun string) string {
+	// prompt on stderr, so that commands can write their output to stdout
+	fmt.Fprint(os.Stderr, prompt+": ")
+	fd := int(os.Stdin.Fd())
+	secret, err := term.ReadPassword(fd)
+	if err == nil {
+		fmt.Fprintln(os.Stderr)
+		return string(secret)
+	}
+	// fall back to a plain read, rather than leaving the user stuck
+	fmt.Fprintln(os.Stderr)
+	if term.IsTerminal(fd) {
+		fmt.Fprintf(os.Stderr, "WARNING: this terminal does not support hidden input (%v).\n", err)
+		fmt.Fprintf(os.Stderr, "WARNING: your %v WILL BE VISIBLE as you type it. Make sure no one can see your screen.\n", noun)
+		fmt.Fprint(os.Stderr, prompt+" (visible): ")
+	} else {
+		fmt.Fprintf(os.Stderr, "WARNING: stdin is not a terminal; reading the %v from it as plain text.\n", noun)
+	}
+	line, err := readLine(os.Stdin)
+	check(err, "Could not read "+noun)
+	return line
+}
+
+// readLine reads a single line from r. It reads one byte at a time, so that no
+// input beyond the line is consumed.
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if err == io.EOF && len(line) > 0 {
+			break
+		} else if err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimSuffix(string(line), "\r"), nil
+}
+
+func getSeed(scheme string) signingSeed {
+	seed, err := parseSeed(readSeedPhrase(scheme), scheme)
+	if err != nil {
+		fatal(err)
+	}
+	return seed
+}
+
+// derivationVectors are known-good key derivations. If a dependency update ever
+// changed how keys are derived, previously-derived addresses would become
+// unsignable; these vectors detect that.
+var derivationVectors = []struct {
+	scheme string
+	seed   string // a phrase for "us", or a hex-encoded raw seed for "siad"
+	index  uint64
+	pubkey string
+}{
+	{"us", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", 0, "ed25519:c3064a3568fc5a38edcd37231f5e1fc016942e74d6ad63570e566c1e6c02c224"},
+	{"us", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", 1, "ed25519:64e1ac15bb62dae218939c708a6c6f8c9816bb2b6a44a49a80817657d3e2fda3"},
+	// the siad seed underlying the "us" seed above, which derives the same keys
+	{"siad", "94c1c088cc9453996779630ad3af45cbd92814828dd784cf2aa12df95d1b8afe", 0, "ed25519:c3064a3568fc5a38edcd37231f5e1fc016942e74d6ad63570e566c1e6c02c224"},
+	{"siad", "94c1c088cc9453996779630ad3af45cbd92814828dd784cf2aa12df95d1b8afe", 1, "ed25519:64e1ac15bb62dae218939c708a6c6f8c9816bb2b6a44a49a80817657d3e2fda3"},
+}
+
+// derivationProblems checks each derivation vector, returning a description of
+// each mismatch.
+func derivationProblems() []string {
+	var problems []string
+	for _, v := range derivationVectors {
+		var seed signingSeed
+		if v.scheme == "siad" {
+			var s modules.Seed
+			b, _ := hex.DecodeString(v.seed)
+			copy(s[:], b)
+			seed = siadSeed(s)
+		} else {
+			var err error
+			if seed, err = parseSeed(v.seed, v.scheme); err != nil {
+				problems = append(problems, fmt.Sprintf("%v seed %q: %v", v.scheme, v.seed, err))
+				continue
+			}
+		}
+		if pk := seed.PublicKey(v.index).String(); pk != v.pubkey {
+			problems = append(problems, fmt.Sprintf("%v seed %q, index %v: derived %v, expected %v", v.scheme, v.seed, v.index, pk, v.pubkey))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,9 @@
+package main
+
+import "testing"
+
+func TestDerivationVectors(t *testing.T) {
+	for _, p := range derivationProblems() {
+		t.Error(p)
+	}
+}
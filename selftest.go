@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"go.sia.tech/multisign/foundation"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/types"
+	"lukechampine.com/us/wallet"
+)
+
+// runSelfTest exercises the full signing workflow using throwaway seeds and a
+// fabricated input, printing PASS or FAIL for each stage. It reports whether
+// every stage passed.
+func runSelfTest() bool {
+	ok := true
+	stage := func(name string, err error) bool {
+		if err != nil {
+			fmt.Printf("FAIL  %v: %v\n", name, err)
+			ok = false
+			return false
+		}
+		fmt.Printf("PASS  %v\n", name)
+		return true
+	}
+
+	// check key derivation against known-good vectors
+	var err error
+	if problems := derivationProblems(); len(problems) != 0 {
+		err = fmt.Errorf("%v", problems[0])
+	}
+	if !stage("derive keys matching test vectors", err) {
+		return false
+	}
+
+	// generate a seed for each co-signer, and check that it survives a
+	// round-trip through its phrase
+	seeds := make([]wallet.Seed, 3)
+	for i := range seeds {
+		seeds[i] = wallet.NewSeed()
+		if s, e := wallet.SeedFromPhrase(seeds[i].String()); e != nil {
+			err = e
+		} else if s.PublicKey(0).String() != seeds[i].PublicKey(0).String() {
+			err = fmt.Errorf("seed %v derives different keys after round-trip", i)
+		}
+	}
+	if !stage("generate seeds", err) {
+		return false
+	}
+
+	// build a 2-of-3 multisig address
+	uc := types.UnlockConditions{SignaturesRequired: 2}
+	for _, seed := range seeds {
+		uc.PublicKeys = append(uc.PublicKeys, seed.PublicKey(0))
+	}
+	err = nil
+	if problems := unlockConditionsProblems(uc); len(problems) != 0 {
+		err = fmt.Errorf("%v", problems[0])
+	}
+	if !stage("derive 2-of-3 address "+uc.UnlockHash().String(), err) {
+		return false
+	}
+
+	// construct a transaction spending a fabricated input
+	spec := txnSpec{
+		Inputs: []specInput{{
+			ParentID:         types.SiacoinOutputID(crypto.HashObject("multisign selftest")),
+			UnlockConditions: uc,
+			Value:            "1000",
+		}},
+		Outputs: []specOutput{{
+			Address: uc.UnlockHash(),
+			Value:   "999",
+		}},
+		FoundationUpdate: &types.FoundationUnlockHashUpdate{
+			NewPrimary:  uc.UnlockHash(),
+			NewFailsafe: uc.UnlockHash(),
+		},
+	}
+	txn, err := buildTxn(spec, -1)
+	if err == nil {
+		if update, e := foundation.DecodeUpdate(txn.ArbitraryData[0]); e != nil {
+			err = e
+		} else if update != *spec.FoundationUpdate {
+			err = fmt.Errorf("Foundation update does not round-trip")
+		}
+	}
+	if !stage("build transaction", err) {
+		return false
+	}
+
+	// sign with two of the three co-signers, checking progress after each
+	height := defaultHeight
+	id := txn.ID()
+	scan := keyScan{Depth: 1, Gap: 1, Max: 1}
+	err = nil
+	if !sign(&txn, seeds[0], scan, height) {
+		err = fmt.Errorf("first co-signer did not produce a signature")
+	} else if e := txn.StandaloneValid(height); e != types.ErrMissingSignatures {
+		err = fmt.Errorf("expected missing signatures after one signature, got %v", e)
+	} else if !sign(&txn, seeds[1], scan, height) {
+		err = fmt.Errorf("second co-signer did not produce a signature")
+	} else if e := txn.StandaloneValid(height); e != nil {
+		err = fmt.Errorf("transaction invalid after two signatures: %v", e)
+	} else if txn.ID() != id {
+		err = fmt.Errorf("transaction ID changed while signing")
+	}
+	if !stage("sign with 2 of 3 keys", err) {
+		return false
+	}
+
+	// round-trip the transaction through the file encoding, then check it as
+	// the check command would
+	f, err := parseTxnFile(encodeTxnFile(txnFile{txn: txn, height: height}))
+	if err == nil && f.txn.ID() != id {
+		err = fmt.Errorf("transaction ID changed after encoding")
+	}
+	if !stage("encode and decode transaction file", err) {
+		return false
+	}
+	fmt.Println()
+	checkTxn(f)
+	fmt.Println()
+	err = nil
+	ucMap := unlockConditionsByID(f.txn)
+	for i := range f.txn.TransactionSignatures {
+		if !validSignature(f.txn, i, ucMap, height) {
+			err = fmt.Errorf("signature %v is invalid", i)
+		}
+	}
+	stage("verify signatures", err)
+	return ok
+}
+
+// fixtureSeeds returns n deterministic seeds. They are derived from public
+// constants, so they must never be used to hold real funds.
+func fixtureSeeds(n int) []wallet.Seed {
+	seeds := make([]wallet.Seed, n)
+	for i := range seeds {
+		var entropy [16]byte
+		h := crypto.HashObject(fmt.Sprintf("multisign fixture seed %v", i))
+		copy(entropy[:], h[:])
+		seeds[i] = wallet.SeedFromEntropy(entropy)
+	}
+	return seeds
+}
+
+// buildFixture builds a byte-for-byte reproducible m-of-n transaction spending a
+// fabricated input, signed by the first sigs fixture seeds.
+func buildFixture(m, n, sigs int) (txnFile, []wallet.Seed, error) {
+	if m < 1 || m > n {
+		return txnFile{}, nil, fmt.Errorf("invalid threshold %v-of-%v", m, n)
+	} else if sigs < 0 || sigs > n {
+		return txnFile{}, nil, fmt.Errorf("cannot add %v signatures with %v keys", sigs, n)
+	}
+	seeds := fixtureSeeds(n)
+	uc := types.UnlockConditions{SignaturesRequired: uint64(m)}
+	for _, seed := range seeds {
+		uc.PublicKeys = append(uc.PublicKeys, seed.PublicKey(0))
+	}
+	spec := txnSpec{
+		Inputs: []specInput{{
+			ParentID:         types.SiacoinOutputID(crypto.HashObject("multisign fixture")),
+			UnlockConditions: uc,
+			Value:            "1000",
+		}},
+		Outputs: []specOutput{{
+			Address: uc.UnlockHash(),
+			Value:   "999",
+		}},
+	}
+	txn, err := buildTxn(spec, -1)
+	if err != nil {
+		return txnFile{}, nil, err
+	}
+	scan := keyScan{Depth: 1, Gap: 1, Max: 1}
+	for _, seed := range seeds[:sigs] {
+		sign(&txn, seed, scan, defaultHeight)
+	}
+	f := txnFile{txn: txn, height: defaultHeight}
+	f.ann.InputValues = specInputValues(spec)
+	f.ann.Comments = []string{"Test fixture generated by multisign fixture; uses throwaway seeds. Do not send funds to its addresses."}
+	return f, seeds, nil
+}
+
+func selfTest() {
+	if !runSelfTest() {
+		fmt.Println("Self-test FAILED.")
+		os.Exit(1)
+	}
+	fmt.Println("Self-test passed.")
+}
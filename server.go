@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.sia.tech/siad/types"
+	"lukechampine.com/us/ed25519hash"
+)
+
+var errAlreadySigned = errors.New("signature(s) already present")
+
+// signingStatus summarizes how many signatures a transaction has, and how
+// many it needs.
+type signingStatus struct {
+	ID         types.TransactionID `json:"id"`
+	Added      int                 `json:"added"`
+	Signatures int                 `json:"signatures"`
+	Required   int                 `json:"required"`
+	Complete   bool                `json:"complete"`
+}
+
+// A coordinator collects signatures for a transaction, persisting each new
+// valid signature to disk.
+type coordinator struct {
+	mu       sync.Mutex
+	filename string
+	file     txnFile
+}
+
+func (c *coordinator) status(added int) signingStatus {
+	s := signingStatus{
+		ID:       c.file.txn.ID(),
+		Added:    added,
+		Complete: c.file.txn.StandaloneValid(c.file.height) == nil,
+	}
+	s.Signatures, s.Required = signingProgress(c.file.txn, c.file.height)
+	return s
+}
+
+// addSignatures verifies sigs and appends them to the transaction. Either all
+// new signatures are added, or none are. Signatures that are already present
+// (by ParentID and PublicKeyIndex) are skipped.
+func (c *coordinator) addSignatures(sigs []types.TransactionSignature) (int, error) {
+	txn := c.file.txn
+	txn.TransactionSignatures = append([]types.TransactionSignature(nil), c.file.txn.TransactionSignatures...)
+	ucMap := unlockConditionsByID(txn)
+	added := 0
+outer:
+	for _, sig := range sigs {
+		for _, existing := range txn.TransactionSignatures {
+			if existing.ParentID == sig.ParentID && existing.PublicKeyIndex == sig.PublicKeyIndex {
+				continue outer
+			}
+		}
+		uc, ok := ucMap[sig.ParentID]
+		if !ok {
+			return 0, fmt.Errorf("signature on %v: no transaction element with that ID", sig.ParentID)
+		} else if sig.PublicKeyIndex >= uint64(len(uc.PublicKeys)) {
+			return 0, fmt.Errorf("signature on %v: public key index is out-of-bounds", sig.ParentID)
+		} else if !sig.CoveredFields.WholeTransaction {
+			return 0, fmt.Errorf("signature on %v: signature does not cover whole transaction", sig.ParentID)
+		}
+		txn.TransactionSignatures = append(txn.TransactionSignatures, sig)
+		spk := uc.PublicKeys[sig.PublicKeyIndex]
+		sigHash := txn.SigHash(len(txn.TransactionSignatures)-1, c.file.height)
+		if spk.Algorithm != types.SignatureEd25519 || !ed25519hash.Verify(spk.Key, sigHash, sig.Signature) {
+			return 0, fmt.Errorf("signature on %v: invalid signature from key %v", sig.ParentID, spk)
+		}
+		added++
+	}
+	if added == 0 {
+		return 0, errAlreadySigned
+	}
+	f := c.file
+	f.txn = txn
+	if err := checkNotArchive(c.filename); err != nil {
+		return 0, err
+	} else if err := ioutil.WriteFile(c.filename, encodeTxnFile(f), 0666); err != nil {
+		return 0, fmt.Errorf("could not write transaction to disk: %w", err)
+	}
+	c.file = f
+	return added, nil
+}
+
+func (c *coordinator) handleTxn(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w.Write(encodeTxnFile(c.file))
+}
+
+func (c *coordinator) handleStatus(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	json.NewEncoder(w).Encode(c.status(0))
+}
+
+func (c *coordinator) handleSignatures(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "could not read request body", http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// accept either a bare array of signatures, or a signed copy of the
+	// transaction
+	var sigs []types.TransactionSignature
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		err = json.Unmarshal(body, &sigs)
+	} else {
+		var txn types.Transaction
+		if err = json.Unmarshal(body, &txn); err == nil {
+			if txn.ID() != c.file.txn.ID() {
+				http.Error(w, "submitted transaction does not match coordinator transaction", http.StatusBadRequest)
+				return
+			}
+			sigs = txn.TransactionSignatures
+		}
+	}
+	if err != nil {
+		http.Error(w, "could not parse signatures: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	added, err := c.addSignatures(sigs)
+	if err == errAlreadySigned {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	status := c.status(added)
+	log.Printf("Added %v signature(s) (%v/%v)", added, status.Signatures, status.Required)
+	if status.Complete {
+		log.Println("Transaction is now fully signed.")
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+func runCoordinator(filename, addr string) {
+	check(checkNotArchive(filename), "Cannot coordinate signing")
+	c := &coordinator{
+		filename: filename,
+		file:     readTxnFile(filename),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/txn", c.handleTxn)
+	mux.HandleFunc("/status", c.handleStatus)
+	mux.HandleFunc("/signatures", c.handleSignatures)
+
+	status := c.status(0)
+	fmt.Println("Transaction ID:", status.ID)
+	fmt.Printf("Signatures: %v/%v\n", status.Signatures, status.Required)
+	fmt.Println("Listening on", addr)
+	log.Fatal(http.ListenAndServe(addr, mux))
+}
+
+func coordinatorRequest(method, url string, body []byte, resp interface{}) error {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode == http.StatusConflict {
+		return errAlreadySigned
+	} else if r.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(r.Body)
+		return errors.New(string(bytes.TrimSpace(msg)))
+	}
+	return json.NewDecoder(r.Body).Decode(resp)
+}
+
+func submitSignatures(coordinatorURL string, scan keyScan, scheme string) {
+	coordinatorURL = strings.TrimSuffix(coordinatorURL, "/")
+	var js json.RawMessage
+	err := coordinatorRequest(http.MethodGet, coordinatorURL+"/txn", nil, &js)
+	check(err, "Could not fetch transaction from coordinator")
+	f, err := parseTxnFile(js)
+	check(err, "Could not parse transaction from coordinator")
+	txn := f.txn
+	if txn.StandaloneValid(f.height) == nil {
+		fmt.Println("Transaction is already fully signed.")
+		return
+	}
+
+	n := len(txn.TransactionSignatures)
+	if err := signTxn(&txn, getSeed(scheme), scan, f.height); err != nil {
+		fatal(err)
+	}
+	js, _ = json.Marshal(txn.TransactionSignatures[n:])
+	var status signingStatus
+	err = coordinatorRequest(http.MethodPost, coordinatorURL+"/signatures", js, &status)
+	if err == errAlreadySigned {
+		log.Fatal("Coordinator already has these signature(s).")
+	}
+	check(err, "Coordinator rejected signature(s)")
+	fmt.Printf("Submitted %v signature(s) (%v/%v collected).\n", status.Added, status.Signatures, status.Required)
+	if status.Complete {
+		fmt.Println("Transaction is now fully signed.")
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.sia.tech/siad/types"
+)
+
+// A siadClient queries the API of a siad node.
+type siadClient struct {
+	addr     string
+	password string
+}
+
+// siadPassword returns the siad API password, read from the SIA_API_PASSWORD
+// environment variable or, failing that, siad's default password file.
+func siadPassword() string {
+	if pw := os.Getenv("SIA_API_PASSWORD"); pw != "" {
+		return pw
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	pw, _ := ioutil.ReadFile(filepath.Join(home, ".sia", "apipassword"))
+	return strings.TrimSpace(string(pw))
+}
+
+// get decodes the response to a GET request for route into r.
+func (c siadClient) get(route string, r interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, "http://"+c.addr+route, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "Sia-Agent")
+	req.SetBasicAuth("", c.password)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return fmt.Errorf("siad returned %v: %v", resp.Status, apiErr.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(r)
+}
+
+// height returns the height of the node's current block.
+func (c siadClient) height() (types.BlockHeight, error) {
+	var r struct {
+		Height types.BlockHeight `json:"height"`
+	}
+	err := c.get("/consensus", &r)
+	return r.Height, err
+}
+
+// confirmed reports whether the transaction with the specified ID has been
+// included in a block on the node's current chain.
+func (c siadClient) confirmed(id types.TransactionID) (bool, error) {
+	var r struct {
+		Confirmed bool `json:"confirmed"`
+	}
+	err := c.get("/tpool/confirmed/"+id.String(), &r)
+	return r.Confirmed, err
+}
+
+// blockContains reports whether the block at the specified height on the
+// node's current chain contains the transaction with the specified ID.
+func (c siadClient) blockContains(height types.BlockHeight, id types.TransactionID) (bool, error) {
+	var r struct {
+		Transactions []struct {
+			ID types.TransactionID `json:"id"`
+		} `json:"transactions"`
+	}
+	if err := c.get(fmt.Sprintf("/consensus/blocks?height=%v", height), &r); err != nil {
+		return false, err
+	}
+	for _, txn := range r.Transactions {
+		if txn.ID == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// inclusionDepth returns the number of confirmations of the confirmed
+// transaction with the specified ID, given the current height, by searching
+// back from the tip for the block that included it. The search stops after
+// limit blocks, in which case the depth is reported as limit+1.
+func (c siadClient) inclusionDepth(id types.TransactionID, tip types.BlockHeight, limit int) (int, error) {
+	for depth := 1; depth <= limit && types.BlockHeight(depth-1) <= tip; depth++ {
+		if ok, err := c.blockContains(tip-types.BlockHeight(depth-1), id); err != nil {
+			return 0, err
+		} else if ok {
+			return depth, nil
+		}
+	}
+	return limit + 1, nil
+}
+
+// unlockConditions fetches the UnlockConditions of addr from the siad wallet.
+func (c siadClient) unlockConditions(addr types.UnlockHash) (types.UnlockConditions, error) {
+	var r struct {
+		UnlockConditions types.UnlockConditions `json:"unlockconditions"`
+	}
+	if err := c.get("/wallet/unlockconditions/"+addr.String(), &r); err != nil {
+		return types.UnlockConditions{}, err
+	} else if r.UnlockConditions.UnlockHash() != addr {
+		return types.UnlockConditions{}, errors.New("siad returned UnlockConditions for a different address")
+	}
+	return r.UnlockConditions, nil
+}
+
+// pubkey fetches the public key of a single-key address from the siad wallet.
+func (c siadClient) pubkey(addr types.UnlockHash) (types.SiaPublicKey, error) {
+	uc, err := c.unlockConditions(addr)
+	if err != nil {
+		return types.SiaPublicKey{}, err
+	} else if len(uc.PublicKeys) != 1 || uc.SignaturesRequired != 1 || uc.Timelock != 0 {
+		return types.SiaPublicKey{}, fmt.Errorf("%v is not a standard single-key address (%v-of-%v, timelock %v)", addr, uc.SignaturesRequired, len(uc.PublicKeys), uc.Timelock)
+	}
+	return uc.PublicKeys[0], nil
+}
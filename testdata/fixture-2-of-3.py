@@ -0,0 +1,157 @@
+#!/usr/bin/env python3
+"""Generates fixture-2-of-3.hex, the golden encoding of the default fixture
+("multisign fixture -m 2 -n 3 -sigs 2"), without using any Go code.
+
+Keys are derived from the fixture seeds as by lukechampine.com/us/wallet, the
+transaction is built and signed at defaultHeight (after the Foundation
+hardfork), and the result is printed in Sia's binary encoding. Usage:
+
+    python3 fixture-2-of-3.py > fixture-2-of-3.hex
+"""
+
+import hashlib
+import struct
+
+
+def H(b):
+    return hashlib.blake2b(b, digest_size=32).digest()
+
+
+def u64(n):
+    return struct.pack('<Q', n)
+
+
+def prefixed(b):
+    return u64(len(b)) + b
+
+
+def currency(n):
+    return prefixed(n.to_bytes((n.bit_length() + 7) // 8, 'big'))
+
+
+# ed25519, following the reference implementation in RFC 8032
+
+p = 2**255 - 19
+L = 2**252 + 27742317777372353535851937790883648493
+d = -121665 * pow(121666, p - 2, p) % p
+Gy = 4 * pow(5, p - 2, p) % p
+Gx = None
+
+
+def recover_x(y, sign):
+    x2 = (y * y - 1) * pow(d * y * y + 1, p - 2, p)
+    x = pow(x2, (p + 3) // 8, p)
+    if (x * x - x2) % p != 0:
+        x = x * pow(2, (p - 1) // 4, p) % p
+    if x & 1 != sign:
+        x = p - x
+    return x
+
+
+Gx = recover_x(Gy, 0)
+G = (Gx, Gy, 1, Gx * Gy % p)
+
+
+def point_add(P, Q):
+    A = (P[1] - P[0]) * (Q[1] - Q[0]) % p
+    B = (P[1] + P[0]) * (Q[1] + Q[0]) % p
+    C = 2 * P[3] * Q[3] * d % p
+    D = 2 * P[2] * Q[2] % p
+    E, F, G_, H_ = B - A, D - C, D + C, B + A
+    return (E * F % p, G_ * H_ % p, F * G_ % p, E * H_ % p)
+
+
+def point_mul(s, P):
+    Q = (0, 1, 1, 0)
+    while s > 0:
+        if s & 1:
+            Q = point_add(Q, P)
+        P = point_add(P, P)
+        s >>= 1
+    return Q
+
+
+def point_compress(P):
+    zinv = pow(P[2], p - 2, p)
+    x, y = P[0] * zinv % p, P[1] * zinv % p
+    return (y | ((x & 1) << 255)).to_bytes(32, 'little')
+
+
+def sha512_int(b):
+    return int.from_bytes(hashlib.sha512(b).digest(), 'little')
+
+
+def secret_expand(seed):
+    h = hashlib.sha512(seed).digest()
+    a = int.from_bytes(h[:32], 'little')
+    a &= (1 << 254) - 8
+    a |= 1 << 254
+    return a, h[32:]
+
+
+def public_key(seed):
+    a, _ = secret_expand(seed)
+    return point_compress(point_mul(a, G))
+
+
+def sign(seed, msg):
+    a, prefix = secret_expand(seed)
+    A = point_compress(point_mul(a, G))
+    r = sha512_int(prefix + msg) % L
+    R = point_compress(point_mul(r, G))
+    h = sha512_int(R + A + msg) % L
+    s = (r + h * a) % L
+    return R + s.to_bytes(32, 'little')
+
+
+# fixture construction, mirroring fixtureSeeds and buildFixture
+
+def hash_string(s):
+    return H(prefixed(s.encode()))  # crypto.HashObject of a string
+
+
+def key_seed(i):
+    entropy = hash_string('multisign fixture seed %d' % i)[:16]
+    siad_seed = H(entropy)  # wallet.SeedFromEntropy
+    return H(siad_seed + u64(0))  # key index 0
+
+
+M, N, SIGS = 2, 3, 2
+SC = 10**24
+ALGORITHM = b'ed25519' + bytes(9)
+
+pubkeys = [public_key(key_seed(i)) for i in range(N)]
+spk = [ALGORITHM + prefixed(pk) for pk in pubkeys]
+
+# the address is the Merkle root of the unlock conditions' fields
+leaves = [H(b'\x00' + leaf) for leaf in [u64(0)] + spk + [u64(M)]]
+
+
+def node(a, b):
+    return H(b'\x01' + a + b)
+
+
+address = node(node(node(leaves[0], leaves[1]), node(leaves[2], leaves[3])), leaves[4])
+
+parent_id = hash_string('multisign fixture')
+unlock_conditions = u64(0) + u64(N) + b''.join(spk) + u64(M)
+
+
+def body(replay_prefix):
+    b = u64(1) + replay_prefix + parent_id + unlock_conditions  # siacoin inputs
+    b += u64(1) + currency(999 * SC) + address  # siacoin outputs
+    b += u64(0) * 5  # contracts, revisions, proofs, siafund inputs and outputs
+    b += u64(1) + currency(1 * SC)  # miner fees: the leftover input value
+    b += u64(0)  # arbitrary data
+    return b
+
+
+FULL_COVERED_FIELDS = b'\x01' + u64(0) * 10
+signatures = []
+for i in range(SIGS):
+    # whole-transaction sighash, with the post-Foundation replay prefix
+    sig_hash = H(body(b'\x01') + parent_id + u64(i) + u64(0))
+    sig = sign(key_seed(i), sig_hash)
+    signatures.append(parent_id + u64(i) + u64(0) + FULL_COVERED_FIELDS + prefixed(sig))
+
+print((body(b'') + u64(len(signatures)) + b''.join(signatures)).hex())
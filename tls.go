@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"io/ioutil"
+	"log"
+	"net/http"
+)
+
+// tlsFlags hold the TLS settings used when connecting to a walrus server that
+// requires mutual TLS.
+type tlsFlags struct {
+	cert string
+	key  string
+	ca   string
+}
+
+func addTLSFlags(cmd *flag.FlagSet) *tlsFlags {
+	tf := new(tlsFlags)
+	cmd.StringVar(&tf.cert, "tls-cert", "", "client certificate to present to the walrus server")
+	cmd.StringVar(&tf.key, "tls-key", "", "private key for the client certificate")
+	cmd.StringVar(&tf.ca, "tls-ca", "", "CA bundle used to verify the walrus server")
+	return tf
+}
+
+// configure applies the TLS settings to the default HTTP client, which is used
+// by the walrus client. If no settings were provided, the default transport is
+// left untouched.
+func (tf *tlsFlags) configure() {
+	if tf.cert == "" && tf.key == "" && tf.ca == "" {
+		return
+	}
+	config := new(tls.Config)
+	if tf.cert != "" || tf.key != "" {
+		if tf.cert == "" || tf.key == "" {
+			log.Fatal("Both -tls-cert and -tls-key must be provided")
+		}
+		cert, err := tls.LoadX509KeyPair(tf.cert, tf.key)
+		check(err, "Could not load client certificate")
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if tf.ca != "" {
+		pem, err := ioutil.ReadFile(tf.ca)
+		check(err, "Could not read CA bundle")
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			log.Fatal("CA bundle does not contain any valid certificates")
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	http.DefaultClient.Transport = transport
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.sia.tech/siad/types"
+	"lukechampine.com/walrus"
+)
+
+// annotations are human-readable notes attached to a transaction file. They
+// are stored alongside the transaction, and are never broadcast.
+type annotations struct {
+	Comments    []string                  `json:"comments,omitempty"`
+	Signers     map[string]string         `json:"signers,omitempty"`
+	InputValues map[string]types.Currency `json:"inputValues,omitempty"` // keyed by ParentID
+}
+
+func (a annotations) isEmpty() bool {
+	return len(a.Comments) == 0 && len(a.Signers) == 0 && len(a.InputValues) == 0
+}
+
+// defaultHeight is the height at which transactions are signed and validated,
+// unless a signing bundle specifies otherwise.
+var defaultHeight = types.FoundationHardforkHeight + 1
+
+// A txnFile is the contents of a transaction file: a transaction, plus any
+// data stored alongside it.
+type txnFile struct {
+	txn     types.Transaction
+	ann     annotations
+	height  types.BlockHeight // height at which to validate the transaction
+	bundle  bool              // whether the file is a signing bundle
+	archive *archiveContents  // the archive the file was read from, if any
+}
+
+// A signingBundle is a self-contained signing request: a transaction, along
+// with all of the context a co-signer needs to verify and sign it.
+type signingBundle struct {
+	Transaction walrus.JSONTransaction `json:"transaction"`
+	Inputs      []bundleInput          `json:"inputs"`
+	Height      types.BlockHeight      `json:"height"`
+	Annotations *annotations           `json:"annotations,omitempty"`
+}
+
+type bundleInput struct {
+	ParentID           types.SiacoinOutputID `json:"parentID"`
+	Address            types.UnlockHash      `json:"address"`
+	UnlockConditions   jsonUnlockConditions  `json:"unlockConditions"`
+	SignaturesRequired uint64                `json:"signaturesRequired"`
+}
+
+func isURL(filename string) bool {
+	return strings.HasPrefix(filename, "http://") || strings.HasPrefix(filename, "https://")
+}
+
+// readFileOrURL reads the contents of filename, fetching it over HTTP if it is
+// a URL.
+func readFileOrURL(filename string) ([]byte, error) {
+	if !isURL(filename) {
+		return ioutil.ReadFile(filename)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %v", resp.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, 10<<20))
+}
+
+func readTxn(filename string) types.Transaction {
+	return readTxnFile(filename).txn
+}
+
+// readTxnFile reads a transaction file, which may be either a plain
+// transaction or a signing bundle.
+func readTxnFile(filename string) txnFile {
+	f, err := loadTxnFile(filename)
+	if err != nil {
+		fatal(err)
+	}
+	return f
+}
+
+// loadTxnFile is like readTxnFile, but returns an error rather than exiting.
+func loadTxnFile(filename string) (txnFile, error) {
+	js, err := readFileOrURL(filename)
+	if err != nil {
+		return txnFile{}, fmt.Errorf("Could not read transaction file: %w", err)
+	}
+	return decodeTxnFile(js)
+}
+
+// decodeTxnFile decodes the contents of a transaction file, which may be a
+// plain transaction, a signing bundle, or an archive.
+func decodeTxnFile(js []byte) (txnFile, error) {
+	var archive *archiveContents
+	if isArchive(js) {
+		c, err := parseArchive(js)
+		if err != nil {
+			return txnFile{}, withKind(errTxnInvalid, fmt.Errorf("Could not read archive: %w", err))
+		}
+		archive = &c
+		js = c.Transaction
+	}
+	f, err := parseTxnFile(js)
+	if err != nil {
+		return txnFile{}, withKind(errTxnInvalid, fmt.Errorf("Could not parse transaction file: %w", err))
+	}
+	f.archive = archive
+	return f, nil
+}
+
+func parseTxnFile(js []byte) (txnFile, error) {
+	var file struct {
+		Transaction json.RawMessage   `json:"transaction"`
+		Height      types.BlockHeight `json:"height"`
+		Annotations annotations       `json:"annotations"`
+	}
+	if err := json.Unmarshal(js, &file); err != nil {
+		return txnFile{}, err
+	}
+	f := txnFile{
+		ann:    file.Annotations,
+		height: defaultHeight,
+	}
+	if len(file.Transaction) != 0 {
+		f.bundle = true
+		f.height = file.Height
+		js = file.Transaction
+	}
+	err := json.Unmarshal(js, &f.txn)
+	return f, err
+}
+
+// extractPastedTxn extracts a transaction file from text pasted from a chat or
+// email, tolerating surrounding prose, code fences, quote markers, and line
+// wrapping. The payload may be JSON or base64-encoded JSON.
+func extractPastedTxn(text []byte) (txnFile, error) {
+	var lines []string
+	for _, line := range strings.Split(string(text), "\n") {
+		line = strings.TrimSpace(line)
+		for strings.HasPrefix(line, ">") {
+			line = strings.TrimSpace(strings.TrimPrefix(line, ">"))
+		}
+		if !strings.HasPrefix(line, "```") {
+			lines = append(lines, line)
+		}
+	}
+	payload := strings.Join(lines, "\n")
+	if start, end := strings.Index(payload, "{"), strings.LastIndex(payload, "}"); start >= 0 && end > start {
+		payload = payload[start : end+1]
+		if f, err := parseTxnFile([]byte(payload)); err == nil {
+			return f, nil
+		}
+		// line wrapping may have split a string; rejoin the lines
+		return parseTxnFile([]byte(strings.Replace(payload, "\n", "", -1)))
+	}
+	payload = strings.Join(strings.Fields(payload), "")
+	if payload == "" {
+		return txnFile{}, errors.New("no transaction found in input")
+	}
+	js, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		if js, err = base64.URLEncoding.DecodeString(payload); err != nil {
+			return txnFile{}, errors.New("input contains neither JSON nor base64")
+		}
+	}
+	return parseTxnFile(js)
+}
+
+// readTxnSet reads either a single transaction or a JSON array of
+// transactions from filename, along with the height at which to validate them:
+// the pinned height of a signing bundle, or defaultHeight otherwise.
+func readTxnSet(filename string) ([]types.Transaction, types.BlockHeight) {
+	js, err := readFileOrURL(filename)
+	check(err, "Could not read transaction file")
+	if !bytes.HasPrefix(bytes.TrimSpace(js), []byte("[")) {
+		f, err := decodeTxnFile(js)
+		if err != nil {
+			fatal(err)
+		}
+		return []types.Transaction{f.txn}, f.height
+	}
+	var txnSet []types.Transaction
+	err = json.Unmarshal(js, &txnSet)
+	check(err, "Could not parse transaction file")
+	if len(txnSet) == 0 {
+		log.Fatal("Transaction file contains no transactions")
+	}
+	return txnSet, defaultHeight
+}
+
+// encodeTxnFile encodes f as JSON. For plain transaction files, any
+// annotations are added as an extra field of the transaction object, where they
+// are ignored by other transaction decoders.
+func encodeTxnFile(f txnFile) []byte {
+	if f.bundle {
+		b := signingBundle{
+			Transaction: walrus.JSONTransaction(f.txn),
+			Inputs:      make([]bundleInput, len(f.txn.SiacoinInputs)),
+			Height:      f.height,
+		}
+		for i, in := range f.txn.SiacoinInputs {
+			b.Inputs[i] = bundleInput{
+				ParentID:           in.ParentID,
+				Address:            in.UnlockConditions.UnlockHash(),
+				UnlockConditions:   jsonUnlockConditions(in.UnlockConditions),
+				SignaturesRequired: in.UnlockConditions.SignaturesRequired,
+			}
+		}
+		if !f.ann.isEmpty() {
+			b.Annotations = &f.ann
+		}
+		js, _ := json.MarshalIndent(b, "", "  ")
+		return append(js, '\n')
+	}
+
+	js, _ := json.Marshal(walrus.JSONTransaction(f.txn))
+	if !f.ann.isEmpty() {
+		annJS, _ := json.Marshal(f.ann)
+		js = js[:len(js)-1] // strip closing brace
+		if len(js) > 1 {
+			js = append(js, ',')
+		}
+		js = append(js, `"annotations":`...)
+		js = append(js, annJS...)
+		js = append(js, '}')
+	}
+	var buf bytes.Buffer
+	json.Indent(&buf, js, "", "  ")
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// checkNotArchive returns an error if filename is an archive. Since archives
+// are read transparently, an in-place edit of one would otherwise replace it
+// with a plain transaction file, discarding its metadata.
+func checkNotArchive(filename string) error {
+	if existing, err := ioutil.ReadFile(filename); err == nil && isArchive(existing) {
+		return fmt.Errorf("refusing to overwrite archive %v; archives are read-only, so write to a different file instead", filename)
+	}
+	return nil
+}
+
+// writeTxnFile writes f to filename, refusing to overwrite an archive.
+func writeTxnFile(filename string, f txnFile) {
+	if isURL(filename) {
+		log.Fatal("Cannot write transaction to a URL; download it to a local file first")
+	}
+	if err := checkNotArchive(filename); err != nil {
+		log.Fatal(err, " (e.g. with -o)")
+	}
+	err := ioutil.WriteFile(filename, encodeTxnFile(f), 0666)
+	check(err, "Could not write transaction to disk")
+}